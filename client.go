@@ -3,13 +3,13 @@ package loops
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strconv"
+	"sync/atomic"
 )
 
 const defaultURL = "https://app.loops.so/api/v1/"
@@ -23,9 +23,24 @@ type HTTPClient interface {
 type RequestInterceptor func(ctx context.Context, req *http.Request) error
 
 type Client struct {
-	apiURL              *url.URL
-	httpClient          HTTPClient
-	requestInterceptors []RequestInterceptor
+	apiURL               *url.URL
+	httpClient           HTTPClient
+	requestInterceptors  []RequestInterceptor
+	limits               *concurrencyLimits
+	lifecycle            *lifecycle
+	flushers             []Flusher
+	hedging              *hedger
+	codec                Codec
+	killSwitches         *KillSwitches
+	divertSink           DivertSink
+	quotas               *quotaEnforcer
+	recipients           *recipientLimiter
+	team                 *teamVerifier
+	readOnly             bool
+	apiKey               atomic.Pointer[string]
+	stats                *clientStats
+	reservedPropertyHook ReservedPropertyHook
+	decodeOptions        decodeOptions
 }
 
 // NewClient creates a new Loops client.
@@ -33,42 +48,106 @@ func NewClient(opts ...ClientOption) (*Client, error) {
 	config := clientConfig{
 		apiURL:     defaultURL,
 		httpClient: http.DefaultClient,
+		codec:      JSONCodec{},
 	}
 	for _, o := range opts {
 		o(&config)
 	}
+	if err := errors.Join(config.errs...); err != nil {
+		return nil, err
+	}
 	apiURL, err := url.Parse(config.apiURL)
 	if err != nil {
 		return nil, fmt.Errorf("invalid api url: %w", err)
 	}
+	if config.codec == nil {
+		return nil, errors.New("codec must not be nil")
+	}
+	limits, err := newConcurrencyLimits(&config)
+	if err != nil {
+		return nil, err
+	}
+	hedging, err := newHedger(config.hedgePolicy)
+	if err != nil {
+		return nil, err
+	}
+	quotas, err := newQuotaEnforcer(&config)
+	if err != nil {
+		return nil, err
+	}
+	recipients, err := newRecipientLimiter(config.recipientPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	flushers := config.flushers
+	if flusher, ok := config.divertSink.(Flusher); ok {
+		flushers = append(flushers, flusher)
+	}
+
+	client := &Client{
+		apiURL:               apiURL,
+		httpClient:           config.httpClient,
+		limits:               limits,
+		lifecycle:            newLifecycle(),
+		flushers:             flushers,
+		hedging:              hedging,
+		codec:                config.codec,
+		killSwitches:         config.killSwitches,
+		divertSink:           config.divertSink,
+		quotas:               quotas,
+		recipients:           recipients,
+		team:                 newTeamVerifier(config.expectedTeam),
+		readOnly:             config.readOnly,
+		stats:                newClientStats(),
+		reservedPropertyHook: config.reservedPropertyHook,
+		decodeOptions:        config.decodeOptions,
+	}
+	client.apiKey.Store(&config.apiKey)
 
 	requestInterceptors := config.requestInterceptors
 
-	if config.apiKey != "" {
-		requestInterceptors = append(requestInterceptors, func(ctx context.Context, req *http.Request) error {
-			bearerToken := fmt.Sprintf("Bearer %s", config.apiKey)
+	requestInterceptors = append(requestInterceptors, func(ctx context.Context, req *http.Request) error {
+		if apiKey := *client.apiKey.Load(); apiKey != "" {
+			bearerToken := fmt.Sprintf("Bearer %s", apiKey)
 			req.Header.Set("Authorization", bearerToken)
-			return nil
-		})
-	}
+		}
+		return nil
+	})
 
 	requestInterceptors = append(requestInterceptors, func(ctx context.Context, req *http.Request) error {
 		req.Header.Set("Content-Type", "application/json")
 		return nil
 	})
+	client.requestInterceptors = requestInterceptors
 
-	return &Client{
-		apiURL:              apiURL,
-		httpClient:          config.httpClient,
-		requestInterceptors: requestInterceptors,
-	}, nil
+	return client, nil
 }
 
 type clientConfig struct {
-	apiURL              string
-	apiKey              string
-	httpClient          HTTPClient
-	requestInterceptors []RequestInterceptor
+	apiURL               string
+	apiKey               string
+	httpClient           HTTPClient
+	requestInterceptors  []RequestInterceptor
+	maxConcurrency       int
+	maxReadConcurrency   int
+	maxWriteConcurrency  int
+	queueWaitObserver    QueueWaitObserver
+	flushers             []Flusher
+	hedgePolicy          *HedgePolicy
+	codec                Codec
+	killSwitches         *KillSwitches
+	divertSink           DivertSink
+	quotas               []Quota
+	quotaStore           CounterStore
+	quotaAlertThreshold  float64
+	quotaAlertHook       QuotaAlertHook
+	recipientPolicy      *RecipientPolicy
+	expectedTeam         string
+	readOnly             bool
+	reservedPropertyHook ReservedPropertyHook
+	decodeOptions        decodeOptions
+	errs                 []error
 }
 
 // ClientOption allows setting custom parameters during construction
@@ -103,15 +182,19 @@ func WithRequestInterceptors(requestInterceptors ...RequestInterceptor) ClientOp
 	}
 }
 
+// SetAPIKey replaces the API key used for all subsequent requests, e.g. after rotating it. If an expected team is
+// configured, it is verified again for the new key.
+func (c *Client) SetAPIKey(apiKey string) {
+	c.apiKey.Store(&apiKey)
+	if c.team != nil {
+		c.team.reset()
+	}
+}
+
 // CreateContact creates a new contact with an email address and any other contact properties.
 // See: https://loops.so/docs/api-reference/create-contact
 func (c *Client) CreateContact(ctx context.Context, contact *Contact) (string, error) {
-	req, err := newRequestWithBody(c, ctx, http.MethodPost, "/contacts/create", contact)
-	if err != nil {
-		return "", err
-	}
-
-	response, err := sendRequest[*IDResponse](c, req)
+	response, err := invoke[*IDResponse](ctx, c, OperationCreateContact, contact, nil)
 	if err != nil {
 		return "", err
 	}
@@ -119,14 +202,26 @@ func (c *Client) CreateContact(ctx context.Context, contact *Contact) (string, e
 }
 
 // UpdateContact updates or creates a contact.
+// All standard fields are sent, so e.g. a contact with Subscribed left false unsubscribes the contact. Use
+// UpdateContactFields to only update the fields that are set.
 // See: https://loops.so/docs/api-reference/update-contact
 func (c *Client) UpdateContact(ctx context.Context, contact *Contact) (string, error) {
-	req, err := newRequestWithBody(c, ctx, http.MethodPut, "/contacts/update", contact)
+	response, err := invoke[*IDResponse](ctx, c, OperationUpdateContact, contact, nil)
 	if err != nil {
 		return "", err
 	}
+	return response.ID, err
+}
 
-	response, err := sendRequest[*IDResponse](c, req)
+// UpdateContactFields updates or creates a contact, only sending the fields of the update that are set and
+// leaving all others unchanged. It is an UpdateContact operation as well, with ContactUpdate as its partial request
+// type, see OperationDescriptor.PartialRequest.
+// See: https://loops.so/docs/api-reference/update-contact
+func (c *Client) UpdateContactFields(ctx context.Context, update *ContactUpdate) (string, error) {
+	if update.Email == "" && update.UserID == "" {
+		return "", errors.New("contact update must contain either an email or a userId")
+	}
+	response, err := invoke[*IDResponse](ctx, c, OperationUpdateContact, update, nil)
 	if err != nil {
 		return "", err
 	}
@@ -150,11 +245,7 @@ func (c *Client) FindContact(ctx context.Context, contact *ContactIdentifier) (*
 	if contact.UserID != nil {
 		params.Add("userId", *contact.UserID)
 	}
-	req, err := newGetRequestWithQueryParams(c, ctx, "/contacts/find", params)
-	if err != nil {
-		return nil, err
-	}
-	contacts, err := sendRequest[[]*Contact](c, req)
+	contacts, err := c.findContacts(ctx, contact, params)
 	if err != nil {
 		return nil, err
 	}
@@ -174,23 +265,14 @@ func (c *Client) DeleteContact(ctx context.Context, contact *ContactIdentifier)
 		return errors.New("contact identifier must contain either an email or a userId, but not both")
 	}
 
-	req, err := newRequestWithBody(c, ctx, http.MethodPost, "/contacts/delete", &contact)
-	if err != nil {
-		return err
-	}
-	_, err = sendRequest[*MessageResponse](c, req)
+	_, err := invoke[*MessageResponse](ctx, c, OperationDeleteContact, contact, nil)
 	return err
 }
 
 // GetMailingLists retrieves a list of an account’s mailing lists.
 // See: https://loops.so/docs/api-reference/get-mailing-lists
 func (c *Client) GetMailingLists(ctx context.Context) ([]*MailingList, error) {
-	req, err := newGetRequestWithQueryParams(c, ctx, "/lists", nil)
-	if err != nil {
-		return nil, err
-	}
-
-	return sendRequest[[]*MailingList](c, req)
+	return invoke[[]*MailingList](ctx, c, OperationGetMailingLists, nil, nil)
 }
 
 // SendEvent sends an event to trigger emails in Loops.
@@ -202,22 +284,14 @@ func (c *Client) SendEvent(ctx context.Context, event *Event) error {
 	if event.Email != nil && event.UserID != nil {
 		return errors.New("event must contain either an email or a userId, but not both")
 	}
-	req, err := newRequestWithBody(c, ctx, http.MethodPost, "/events/send", event)
-	if err != nil {
-		return err
-	}
-	_, err = sendRequest[*MessageResponse](c, req)
+	_, err := invoke[*MessageResponse](ctx, c, OperationSendEvent, event, nil)
 	return err
 }
 
 // SendTransactionalEmail sends a transactional email to a contact.
 // See: https://loops.so/docs/api-reference/send-transactional-email
 func (c *Client) SendTransactionalEmail(ctx context.Context, transactional *TransactionalEmail) error {
-	req, err := newRequestWithBody(c, ctx, http.MethodPost, "/transactional", transactional)
-	if err != nil {
-		return err
-	}
-	_, err = sendRequest[*MessageResponse](c, req)
+	_, err := invoke[*MessageResponse](ctx, c, OperationSendTransactionalEmail, transactional, nil)
 	return err
 }
 
@@ -243,41 +317,29 @@ func (c *Client) GetContactProperties(ctx context.Context, opts ContactPropertyL
 	} else if opts.List != ContactPropertyTypeAll {
 		return nil, errors.New("invalid list type")
 	}
-	req, err := newGetRequestWithQueryParams(c, ctx, "/contacts/properties", params)
-	if err != nil {
-		return nil, err
-	}
-	return sendRequest[[]*ContactProperty](c, req)
+	return invoke[[]*ContactProperty](ctx, c, OperationGetContactProperties, opts, params)
 }
 
 // CreateContactProperty creates a new contact property.
+// The name must be in camelCase and must not be reserved by a standard contact field, which is checked before sending.
 // See: https://loops.so/docs/api-reference/create-contact-property
 func (c *Client) CreateContactProperty(ctx context.Context, property *ContactPropertyCreate) error {
-	req, err := newRequestWithBody(c, ctx, http.MethodPost, "/contacts/properties", property)
-	if err != nil {
+	if err := property.validate(); err != nil {
 		return err
 	}
-	_, err = sendRequest[*SuccessResponse](c, req)
+	_, err := invoke[*SuccessResponse](ctx, c, OperationCreateContactProperty, property, nil)
 	return err
 }
 
 // Deprecated: Use GetContactProperties instead.
 func (c *Client) GetCustomFields(ctx context.Context) ([]*ContactProperty, error) {
-	req, err := newGetRequestWithQueryParams(c, ctx, "/contacts/customFields", nil)
-	if err != nil {
-		return nil, err
-	}
-	return sendRequest[[]*ContactProperty](c, req)
+	return invoke[[]*ContactProperty](ctx, c, OperationGetCustomFields, nil, nil)
 }
 
 // GetDedicatedSendingIPs retrieves a list of Loops' dedicated sending IP addresses.
 // See: https://loops.so/docs/api-reference/list-dedicated-sending-ips
 func (c *Client) GetDedicatedSendingIPs(ctx context.Context) ([]string, error) {
-	req, err := newGetRequestWithQueryParams(c, ctx, "/dedicated-sending-ips", nil)
-	if err != nil {
-		return nil, err
-	}
-	return sendRequest[[]string](c, req)
+	return invoke[[]string](ctx, c, OperationGetDedicatedSendingIPs, nil, nil)
 }
 
 type ListTransactionalEmailsOptions struct {
@@ -302,26 +364,53 @@ func (c *Client) ListTransactionalEmails(ctx context.Context, opts ListTransacti
 	if opts.Cursor != "" {
 		params.Add("cursor", opts.Cursor)
 	}
-	req, err := newGetRequestWithQueryParams(c, ctx, "/transactional", params)
-	if err != nil {
-		return nil, err
-	}
-	return sendRequest[*TransactionalEmailList](c, req)
+	return invoke[*TransactionalEmailList](ctx, c, OperationListTransactionalEmails, opts, params)
 }
 
 // TestAPIKey tests that an API key is valid.
 // See: https://loops.so/docs/api-reference/api-key
 func (c *Client) TestAPIKey(ctx context.Context) (*APIKeyInfo, error) {
-	req, err := newGetRequestWithQueryParams(c, ctx, "/api-key", nil)
-	if err != nil {
-		return nil, err
+	return invoke[*APIKeyInfo](ctx, c, OperationTestAPIKey, nil, nil)
+}
+
+// invoke calls the given operation as described by its descriptor: it checks whether the call may proceed, sends
+// the payload as JSON body (or the query parameters for GET requests), and unmarshals the response.
+func invoke[T any](ctx context.Context, c *Client, op Operation, payload any, params url.Values) (T, error) {
+	response, err := call[T](ctx, c, op, payload, params)
+	c.stats.record(op, err)
+	return response, err
+}
+
+// call implements invoke, without recording the call in the client's stats.
+func call[T any](ctx context.Context, c *Client, op Operation, payload any, params url.Values) (T, error) {
+	var none T
+	descriptor, ok := LookupOperation(op)
+	if !ok {
+		return none, fmt.Errorf("unknown operation: %s", op)
+	}
+	if err := c.lifecycle.checkOpen(); err != nil {
+		return none, err
+	}
+	payload = c.dropReservedProperties(ctx, payload)
+	if ok, err := c.allow(ctx, op, payload); !ok {
+		return none, err
 	}
 
-	return sendRequest[*APIKeyInfo](c, req)
+	var req *http.Request
+	var err error
+	if descriptor.Method == http.MethodGet {
+		req, err = newGetRequestWithQueryParams(c, ctx, descriptor.Path, params)
+	} else {
+		req, err = newRequestWithBody(c, ctx, descriptor.Method, descriptor.Path, payload)
+	}
+	if err != nil {
+		return none, err
+	}
+	return sendRequest[T](c, req)
 }
 
 func newGetRequestWithQueryParams(c *Client, ctx context.Context, path string, queryParams url.Values) (*http.Request, error) {
-	req, err := newRequestWithBody[Contact](c, ctx, http.MethodGet, path, nil)
+	req, err := newRequestWithBody(c, ctx, http.MethodGet, path, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -332,7 +421,7 @@ func newGetRequestWithQueryParams(c *Client, ctx context.Context, path string, q
 	return req, nil
 }
 
-func newRequestWithBody[T any](c *Client, ctx context.Context, method, path string, message *T) (*http.Request, error) {
+func newRequestWithBody(c *Client, ctx context.Context, method, path string, message any) (*http.Request, error) {
 	if path[0] == '/' {
 		path = "." + path
 	}
@@ -344,7 +433,7 @@ func newRequestWithBody[T any](c *Client, ctx context.Context, method, path stri
 
 	var body io.Reader
 	if message != nil {
-		buf, err := json.Marshal(message)
+		buf, err := c.codec.Marshal(message)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal message: %w", err)
 		}
@@ -364,43 +453,114 @@ func newRequestWithBody[T any](c *Client, ctx context.Context, method, path stri
 	return req, nil
 }
 
-func sendRequest[T any](c *Client, req *http.Request) (T, error) {
-	var none T
+// allow checks whether a call of the given operation may proceed, before any request is built. It returns false
+// if it may not, together with the reason, or without an error if the call was diverted to a sink instead.
+func (c *Client) allow(ctx context.Context, op Operation, payload any) (bool, error) {
+	if err := c.checkReadOnly(op); err != nil {
+		return false, err
+	}
+	if c.team != nil && op != OperationTestAPIKey { // TestAPIKey is how the team is verified
+		if err := c.team.verify(ctx, c); err != nil {
+			return false, err
+		}
+	}
+	if ok, err := c.checkKillSwitch(ctx, op, payload); !ok {
+		return false, err
+	}
+	if c.recipients != nil {
+		if ok, err := c.recipients.check(ctx, payload); !ok {
+			return false, err
+		}
+	}
+	if c.quotas != nil {
+		if err := c.quotas.check(ctx, payload); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// roundTrip sends the request and reads the full response body, respecting the client's lifecycle and
+// concurrency limits.
+func (c *Client) roundTrip(req *http.Request) (*http.Response, []byte, error) {
+	req, done, err := c.lifecycle.begin(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer done()
+
+	if c.hedging != nil && req.Method == http.MethodGet {
+		return c.hedging.roundTrip(c, req)
+	}
+
+	release, err := c.limits.acquire(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+	return c.send(req)
+}
+
+// send sends the request and reads the full response body.
+func (c *Client) send(req *http.Request) (*http.Response, []byte, error) {
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return none, fmt.Errorf("failed to send request %s: %w", req.URL.String(), err)
+		return nil, nil, fmt.Errorf("failed to send request %s: %w", req.URL.String(), err)
 	}
 	defer func() { _ = resp.Body.Close() }()
+	c.stats.observeRateLimit(resp.Header)
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return none, fmt.Errorf("failed to read response body: %w", err)
+		return nil, nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return resp, body, nil
+}
+
+func sendRequest[T any](c *Client, req *http.Request) (T, error) {
+	var response T
+	if err := c.sendRequestInto(req, &response, false); err != nil {
+		var none T
+		return none, err
+	}
+	return response, nil
+}
+
+// sendRequestInto sends the request and unmarshals a successful response into out, unless out is nil. An empty
+// successful response is only accepted if allowEmpty is set, and leaves out unchanged. Error responses are mapped to
+// an error with the message returned by the API.
+func (c *Client) sendRequestInto(req *http.Request, out any, allowEmpty bool) error {
+	resp, body, err := c.roundTrip(req)
+	if err != nil {
+		return err
 	}
 
 	if resp.StatusCode < 300 { // success response
-		var response T
-		err = json.Unmarshal(body, &response)
+		if out == nil || (allowEmpty && len(body) == 0) {
+			return nil
+		}
+		err = c.codec.Unmarshal(body, out)
 		if err != nil {
-			return none, fmt.Errorf("failed to unmarshal response body: %w", err)
+			return fmt.Errorf("failed to unmarshal response body: %w", err)
 		}
-		return response, nil
+		return nil
 	}
 
 	// sometimes loops returns an "error": message, so check if that's the case and if so, return the error
 	errorMsg := &errorResponse{}
-	err = json.Unmarshal(body, &errorMsg)
+	err = c.codec.Unmarshal(body, errorMsg)
 	if err == nil && errorMsg.Error != "" {
-		return none, errors.New(errorMsg.Error)
+		return errors.New(errorMsg.Error)
 	}
 
 	// error, get the message and return it
 	msg := &MessageResponse{}
-	err = json.Unmarshal(body, &msg)
+	err = c.codec.Unmarshal(body, msg)
 	if err != nil {
-		return none, fmt.Errorf("failed to unmarshal error message: %w", err)
+		return fmt.Errorf("failed to unmarshal error message: %w", err)
 	}
 	if msg.Message == "" {
-		return none, errors.New(string(body))
+		return errors.New(string(body))
 	}
-	return none, errors.New(msg.Message)
+	return errors.New(msg.Message)
 }
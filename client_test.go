@@ -2,6 +2,7 @@ package loops
 
 import (
 	"context"
+	"net/http"
 	"os"
 	"path"
 	"strings"
@@ -34,11 +35,11 @@ func newRecordTestClient(t *testing.T, recordingFile string) *Client { //nolint:
 	return client
 }
 
-func newReplayTestClient(t *testing.T, recordingFile string) *Client {
+func newReplayTestClient(t *testing.T, recordingFile string, opts ...ClientOption) *Client {
 	replayer, err := httpreplay.NewReplayer(path.Join(TestdataDir(), recordingFile))
 	require.NoError(t, err)
 	t.Cleanup(func() { _ = replayer.Close() })
-	client, err := NewClient(WithHTTPClient(replayer.Client()))
+	client, err := NewClient(append(opts, WithHTTPClient(replayer.Client()))...)
 	require.NoError(t, err)
 	return client
 }
@@ -72,6 +73,24 @@ func TestUpdateContact(t *testing.T) {
 	assert.Equal(t, "cmk6vyub00c7b0i04dlregeit", contactID)
 }
 
+func TestUpdateContactFields(t *testing.T) {
+	httpClient := &recordingHTTPClient{}
+	client, err := NewClient(WithHTTPClient(httpClient))
+	require.NoError(t, err)
+
+	contactID, err := client.UpdateContactFields(context.Background(), &ContactUpdate{
+		Email:     "test@example.com",
+		UserGroup: Set("Astronauts"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "123", contactID)
+	require.Len(t, httpClient.bodies, 1)
+	assert.JSONEq(t, `{"email":"test@example.com","userGroup":"Astronauts"}`, httpClient.bodies[0])
+
+	_, err = client.UpdateContactFields(context.Background(), &ContactUpdate{UserGroup: Set("Astronauts")})
+	require.Error(t, err)
+}
+
 func TestFindContact(t *testing.T) {
 	client := newReplayTestClient(t, "find-contact.replay.json")
 	contact, err := client.FindContact(context.Background(), &ContactIdentifier{
@@ -132,7 +151,7 @@ func TestGetContactProperties(t *testing.T) {
 	require.Len(t, allProperties, 14)
 	assert.Equal(t, "firstName", allProperties[0].Key)
 	assert.Equal(t, "First Name", allProperties[0].Label)
-	assert.Equal(t, "string", allProperties[0].Type)
+	assert.Equal(t, PropertyTypeString, allProperties[0].Type)
 	assert.Equal(t, "lastName", allProperties[1].Key)
 
 	customProperties, err := client.GetContactProperties(context.Background(), ContactPropertyListOptions{
@@ -142,7 +161,7 @@ func TestGetContactProperties(t *testing.T) {
 	require.Len(t, customProperties, 2)
 	assert.Equal(t, "heardAboutChannel", customProperties[0].Key)
 	assert.Equal(t, "Heard About Channel", customProperties[0].Label)
-	assert.Equal(t, "string", customProperties[0].Type)
+	assert.Equal(t, PropertyTypeString, customProperties[0].Type)
 	assert.Equal(t, "companyRole", customProperties[1].Key)
 }
 
@@ -150,11 +169,31 @@ func TestCreateContactProperty(t *testing.T) {
 	client := newReplayTestClient(t, "create-contact-property.replay.json")
 	err := client.CreateContactProperty(context.Background(), &ContactPropertyCreate{
 		Name: "planName",
-		Type: "string",
+		Type: PropertyTypeString,
 	})
 	require.NoError(t, err)
 }
 
+func TestCreateContactPropertyInvalid(t *testing.T) {
+	client, err := NewClient(WithHTTPClient(httpClientFunc(func(*http.Request) (*http.Response, error) {
+		t.Fatal("invalid properties must not be sent")
+		return nil, nil
+	})))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	for _, name := range []string{"", "PlanName", "plan_name", "plan name", "1plan"} {
+		err := client.CreateContactProperty(ctx, &ContactPropertyCreate{Name: name, Type: PropertyTypeString})
+		require.ErrorIs(t, err, ErrInvalidPropertyName, name)
+	}
+	for _, name := range []string{"email", "userGroup", "createdAt"} {
+		err := client.CreateContactProperty(ctx, &ContactPropertyCreate{Name: name, Type: PropertyTypeString})
+		require.ErrorIs(t, err, ErrReservedProperty, name)
+	}
+	err = client.CreateContactProperty(ctx, &ContactPropertyCreate{Name: "planName", Type: "text"})
+	require.EqualError(t, err, `invalid property type: "text"`)
+}
+
 func TestDeleteContact(t *testing.T) {
 	client := newReplayTestClient(t, "delete-contact.replay.json")
 	err := client.DeleteContact(context.Background(), &ContactIdentifier{
@@ -0,0 +1,33 @@
+package loops
+
+import "encoding/json"
+
+// Codec encodes request bodies and decodes response bodies.
+// Implementations must honour the json.Marshaler and json.Unmarshaler interfaces, since model types such as Contact
+// rely on them to inline their custom properties. Drop-in replacements for encoding/json generally do.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec is the default Codec, based on encoding/json.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	if appender, ok := v.(jsonAppender); ok {
+		return marshalAppender(appender)
+	}
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// WithCodec allows overriding the codec used for request and response bodies (default: JSONCodec), e.g. to use a
+// faster JSON implementation
+func WithCodec(codec Codec) ClientOption {
+	return func(c *clientConfig) {
+		c.codec = codec
+	}
+}
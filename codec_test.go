@@ -0,0 +1,63 @@
+package loops
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingCodec is a custom codec, that records how often it was used.
+type countingCodec struct {
+	marshaled   int
+	unmarshaled int
+}
+
+func (c *countingCodec) Marshal(v any) ([]byte, error) {
+	c.marshaled++
+	return json.Marshal(v)
+}
+
+func (c *countingCodec) Unmarshal(data []byte, v any) error {
+	c.unmarshaled++
+	return json.Unmarshal(data, v)
+}
+
+func TestCustomCodec(t *testing.T) {
+	var requestBody []byte
+	httpClient := httpClientFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Body != nil {
+			requestBody, _ = io.ReadAll(req.Body)
+			return jsonResponse(http.StatusOK, `{"success":true,"id":"123"}`), nil
+		}
+		return jsonResponse(http.StatusOK, `[{"id":"123","email":"test@example.com","subscribed":true,"companyRole":"Developer"}]`), nil
+	})
+	codec := &countingCodec{}
+	client, err := NewClient(WithHTTPClient(httpClient), WithCodec(codec))
+	require.NoError(t, err)
+
+	contactID, err := client.CreateContact(context.Background(), &Contact{
+		Email:      "test@example.com",
+		Subscribed: true,
+		Properties: map[string]any{"companyRole": "Developer"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "123", contactID)
+	assert.JSONEq(t, `{"id":"","email":"test@example.com","subscribed":true,"companyRole":"Developer"}`, string(requestBody))
+
+	contact, err := client.FindContact(context.Background(), &ContactIdentifier{Email: String("test@example.com")})
+	require.NoError(t, err)
+	assert.Equal(t, "Developer", contact.Properties["companyRole"])
+
+	assert.Equal(t, 1, codec.marshaled)
+	assert.Equal(t, 2, codec.unmarshaled)
+}
+
+func TestNilCodec(t *testing.T) {
+	_, err := NewClient(WithCodec(nil))
+	require.Error(t, err)
+}
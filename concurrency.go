@@ -0,0 +1,148 @@
+package loops
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// QueueWaitObserver is called for every request that had to pass a concurrency limit, with the time it spent
+// waiting for a free slot. It can be used to export metrics on queue wait time.
+type QueueWaitObserver func(ctx context.Context, req *http.Request, wait time.Duration)
+
+// WithMaxConcurrency limits the number of requests the client has in flight at the same time.
+// Calls exceeding the limit wait for a free slot, or until their context is done.
+// The limit is shared between read and write requests, unless separate limits are configured
+// using WithMaxReadConcurrency or WithMaxWriteConcurrency.
+func WithMaxConcurrency(n int) ClientOption {
+	return func(c *clientConfig) {
+		c.maxConcurrency = n
+	}
+}
+
+// WithMaxReadConcurrency limits the number of read (GET) requests in flight at the same time, using a pool
+// separate from write requests.
+func WithMaxReadConcurrency(n int) ClientOption {
+	return func(c *clientConfig) {
+		c.maxReadConcurrency = n
+	}
+}
+
+// WithMaxWriteConcurrency limits the number of write (non-GET) requests in flight at the same time, using a pool
+// separate from read requests.
+func WithMaxWriteConcurrency(n int) ClientOption {
+	return func(c *clientConfig) {
+		c.maxWriteConcurrency = n
+	}
+}
+
+// WithQueueWaitObserver registers a callback that is invoked with the time each request spent waiting for
+// a concurrency slot.
+func WithQueueWaitObserver(observer QueueWaitObserver) ClientOption {
+	return func(c *clientConfig) {
+		c.queueWaitObserver = observer
+	}
+}
+
+// semaphore is a context aware counting semaphore, limiting the number of concurrent requests.
+type semaphore chan struct{}
+
+func newSemaphore(n int) semaphore {
+	if n == 0 {
+		return nil
+	}
+	return make(semaphore, n)
+}
+
+// acquire blocks until a slot is free or the context is done.
+func (s semaphore) acquire(ctx context.Context) error {
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// tryAcquire acquires a slot only if one is immediately available.
+func (s semaphore) tryAcquire() bool {
+	select {
+	case s <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s semaphore) release() {
+	<-s
+}
+
+// concurrencyLimits holds the semaphores limiting in-flight requests, one for reads and one for writes.
+// Both may point to the same semaphore if only a shared limit is configured, or be nil if there is no limit.
+type concurrencyLimits struct {
+	reads    semaphore
+	writes   semaphore
+	observer QueueWaitObserver
+}
+
+func newConcurrencyLimits(config *clientConfig) (*concurrencyLimits, error) {
+	for name, n := range map[string]int{
+		"max concurrency":       config.maxConcurrency,
+		"max read concurrency":  config.maxReadConcurrency,
+		"max write concurrency": config.maxWriteConcurrency,
+	} {
+		if n < 0 {
+			return nil, fmt.Errorf("invalid %s: %d, must not be negative", name, n)
+		}
+	}
+
+	shared := newSemaphore(config.maxConcurrency)
+	limits := &concurrencyLimits{reads: shared, writes: shared, observer: config.queueWaitObserver}
+	if config.maxReadConcurrency > 0 {
+		limits.reads = newSemaphore(config.maxReadConcurrency)
+	}
+	if config.maxWriteConcurrency > 0 {
+		limits.writes = newSemaphore(config.maxWriteConcurrency)
+	}
+	return limits, nil
+}
+
+func (l *concurrencyLimits) pool(req *http.Request) semaphore {
+	if req.Method == http.MethodGet {
+		return l.reads
+	}
+	return l.writes
+}
+
+// acquire waits for a free slot for the given request, returning a function to release it again.
+func (l *concurrencyLimits) acquire(req *http.Request) (func(), error) {
+	pool := l.pool(req)
+	if pool == nil {
+		return func() {}, nil
+	}
+
+	start := time.Now()
+	err := pool.acquire(req.Context())
+	if l.observer != nil {
+		l.observer(req.Context(), req, time.Since(start))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire concurrency slot for request %s: %w", req.URL.String(), err)
+	}
+	return pool.release, nil
+}
+
+// tryAcquire acquires a slot for the given request only if one is immediately available, returning a function to
+// release it again.
+func (l *concurrencyLimits) tryAcquire(req *http.Request) (func(), bool) {
+	pool := l.pool(req)
+	if pool == nil {
+		return func() {}, true
+	}
+	if !pool.tryAcquire() {
+		return nil, false
+	}
+	return pool.release, true
+}
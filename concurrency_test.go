@@ -0,0 +1,132 @@
+package loops
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type httpClientFunc func(req *http.Request) (*http.Response, error)
+
+func (f httpClientFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func jsonResponse(statusCode int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: statusCode,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+// blockingHTTPClient records the maximum number of concurrent requests, and blocks every request until unblocked.
+type blockingHTTPClient struct {
+	inFlight    atomic.Int64
+	maxInFlight atomic.Int64
+	unblock     chan struct{}
+}
+
+func (b *blockingHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	n := b.inFlight.Add(1)
+	defer b.inFlight.Add(-1)
+	for {
+		current := b.maxInFlight.Load()
+		if n <= current || b.maxInFlight.CompareAndSwap(current, n) {
+			break
+		}
+	}
+
+	select {
+	case <-b.unblock:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	if req.Method == http.MethodGet {
+		return jsonResponse(http.StatusOK, `[]`), nil
+	}
+	return jsonResponse(http.StatusOK, `{"success":true,"id":"123"}`), nil
+}
+
+func TestMaxConcurrency(t *testing.T) {
+	httpClient := &blockingHTTPClient{unblock: make(chan struct{})}
+	client, err := NewClient(WithHTTPClient(httpClient), WithMaxConcurrency(2))
+	require.NoError(t, err)
+
+	wg := sync.WaitGroup{}
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.UpdateContact(context.Background(), &Contact{Email: "test@example.com"})
+			assert.NoError(t, err)
+		}()
+	}
+
+	// wait until the limit is reached, then release all requests
+	require.Eventually(t, func() bool { return httpClient.inFlight.Load() == 2 }, time.Second, time.Millisecond)
+	close(httpClient.unblock)
+	wg.Wait()
+	assert.Equal(t, int64(2), httpClient.maxInFlight.Load())
+}
+
+func TestMaxConcurrencyContextCancelled(t *testing.T) {
+	httpClient := &blockingHTTPClient{unblock: make(chan struct{})}
+	defer close(httpClient.unblock)
+
+	var waited atomic.Int64
+	client, err := NewClient(WithHTTPClient(httpClient), WithMaxConcurrency(1),
+		WithQueueWaitObserver(func(_ context.Context, _ *http.Request, _ time.Duration) {
+			waited.Add(1)
+		}),
+	)
+	require.NoError(t, err)
+
+	go func() {
+		_, _ = client.UpdateContact(context.Background(), &Contact{Email: "test@example.com"})
+	}()
+	require.Eventually(t, func() bool { return httpClient.inFlight.Load() == 1 }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = client.UpdateContact(ctx, &Contact{Email: "test@example.com"})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, int64(2), waited.Load())
+}
+
+func TestMaxReadWriteConcurrencySeparatePools(t *testing.T) {
+	httpClient := &blockingHTTPClient{unblock: make(chan struct{})}
+	client, err := NewClient(WithHTTPClient(httpClient), WithMaxReadConcurrency(1), WithMaxWriteConcurrency(1))
+	require.NoError(t, err)
+
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, err := client.UpdateContact(context.Background(), &Contact{Email: "test@example.com"})
+		assert.NoError(t, err)
+	}()
+	go func() {
+		defer wg.Done()
+		_, err := client.GetMailingLists(context.Background())
+		assert.NoError(t, err)
+	}()
+
+	// a read and a write can be in flight at the same time, since they use different pools
+	require.Eventually(t, func() bool { return httpClient.inFlight.Load() == 2 }, time.Second, time.Millisecond)
+	close(httpClient.unblock)
+	wg.Wait()
+}
+
+func TestMaxConcurrencyInvalid(t *testing.T) {
+	_, err := NewClient(WithMaxConcurrency(-1))
+	require.Error(t, err)
+}
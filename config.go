@@ -0,0 +1,317 @@
+package loops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const defaultConfigReloadInterval = 10 * time.Second
+
+// Config is the configuration of a client, as loaded by LoadConfig from a file and environment variables.
+type Config struct {
+	// The API URL (default: https://app.loops.so/api/v1/). Environment variable: LOOPS_API_URL.
+	APIURL string `json:"apiUrl,omitempty" yaml:"apiUrl,omitempty"`
+	// The loops API key. Environment variable: LOOPS_API_KEY.
+	APIKey string `json:"apiKey,omitempty" yaml:"apiKey,omitempty"`
+	// Limits on the number of concurrent requests, see WithMaxConcurrency. Zero means no limit.
+	// Environment variable: LOOPS_MAX_CONCURRENCY.
+	MaxConcurrency      int `json:"maxConcurrency,omitempty" yaml:"maxConcurrency,omitempty"`
+	MaxReadConcurrency  int `json:"maxReadConcurrency,omitempty" yaml:"maxReadConcurrency,omitempty"`
+	MaxWriteConcurrency int `json:"maxWriteConcurrency,omitempty" yaml:"maxWriteConcurrency,omitempty"`
+	// The team the API key is expected to belong to, see WithExpectedTeam. Environment variable: LOOPS_EXPECTED_TEAM.
+	ExpectedTeam string `json:"expectedTeam,omitempty" yaml:"expectedTeam,omitempty"`
+	// Whether the client is read-only, see WithReadOnly. Environment variable: LOOPS_READ_ONLY.
+	ReadOnly bool `json:"readOnly,omitempty" yaml:"readOnly,omitempty"`
+	// Operations disabled by a kill switch. Environment variable: LOOPS_DISABLED_OPERATIONS (comma separated).
+	DisabledOperations []Operation `json:"disabledOperations,omitempty" yaml:"disabledOperations,omitempty"`
+	// Local send quotas, see WithQuotas.
+	Quotas []Quota `json:"quotas,omitempty" yaml:"quotas,omitempty"`
+	// The file quota counters are persisted to. If empty, counters are kept in memory.
+	QuotaFile string `json:"quotaFile,omitempty" yaml:"quotaFile,omitempty"`
+	// Per-recipient send limits, see WithRecipientPolicy.
+	RecipientPolicy *RecipientPolicy `json:"recipientPolicy,omitempty" yaml:"recipientPolicy,omitempty"`
+
+	// path is the file the config was loaded from, so a ConfigWatcher can reload it.
+	path string
+}
+
+// LoadConfig loads the client configuration from the JSON or YAML file at the given path, and overrides it with
+// the LOOPS_* environment variables documented on Config. If path is empty, the file at LOOPS_CONFIG_FILE is used,
+// and if that is not set either, the configuration is loaded from environment variables only.
+// Durations are given as strings such as "1h" or "30s". The loaded configuration is validated.
+func LoadConfig(path string) (*Config, error) {
+	if path == "" {
+		path = os.Getenv("LOOPS_CONFIG_FILE")
+	}
+	config := &Config{path: path}
+
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+		decoder := yaml.NewDecoder(f) // YAML is a superset of JSON, so this handles both
+		decoder.KnownFields(true)
+		if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	}
+
+	if err := config.loadEnv(); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Validate checks the configuration for errors, returning all of them.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.APIURL != "" {
+		if u, err := url.Parse(c.APIURL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("invalid api url: %q", c.APIURL))
+		}
+	}
+	if c.MaxConcurrency < 0 || c.MaxReadConcurrency < 0 || c.MaxWriteConcurrency < 0 {
+		errs = append(errs, errors.New("invalid concurrency limit: must not be negative"))
+	}
+	for _, op := range c.DisabledOperations {
+		if !slices.Contains(allOperations, op) {
+			errs = append(errs, fmt.Errorf("unknown operation: %q", op))
+		}
+	}
+	for _, q := range c.Quotas {
+		if err := q.validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.RecipientPolicy != nil {
+		if err := c.RecipientPolicy.validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Options returns the client options for the configuration, to pass to NewClient. Kill switches are always
+// configured, so disabled operations can be changed at runtime by a ConfigWatcher.
+func (c *Config) Options() ([]ClientOption, error) {
+	opts := []ClientOption{WithAPIKey(c.APIKey)}
+	if c.APIURL != "" {
+		opts = append(opts, WithURL(c.APIURL))
+	}
+	if c.MaxConcurrency > 0 {
+		opts = append(opts, WithMaxConcurrency(c.MaxConcurrency))
+	}
+	if c.MaxReadConcurrency > 0 {
+		opts = append(opts, WithMaxReadConcurrency(c.MaxReadConcurrency))
+	}
+	if c.MaxWriteConcurrency > 0 {
+		opts = append(opts, WithMaxWriteConcurrency(c.MaxWriteConcurrency))
+	}
+	if c.ExpectedTeam != "" {
+		opts = append(opts, WithExpectedTeam(c.ExpectedTeam))
+	}
+	if c.ReadOnly {
+		opts = append(opts, WithReadOnly())
+	}
+
+	killSwitches := NewKillSwitches()
+	killSwitches.Disable(c.DisabledOperations...)
+	opts = append(opts, WithKillSwitches(killSwitches))
+
+	if len(c.Quotas) > 0 {
+		var store CounterStore = NewMemoryCounterStore()
+		if c.QuotaFile != "" {
+			fileStore, err := NewFileCounterStore(c.QuotaFile)
+			if err != nil {
+				return nil, err
+			}
+			store = fileStore
+		}
+		opts = append(opts, WithQuotas(store, c.Quotas...))
+	}
+	if c.RecipientPolicy != nil {
+		opts = append(opts, WithRecipientPolicy(*c.RecipientPolicy))
+	}
+	return opts, nil
+}
+
+// loadEnv overrides the configuration with the values of the LOOPS_* environment variables that are set.
+func (c *Config) loadEnv() error {
+	if v, ok := os.LookupEnv("LOOPS_API_URL"); ok {
+		c.APIURL = v
+	}
+	if v, ok := os.LookupEnv("LOOPS_API_KEY"); ok {
+		c.APIKey = v
+	}
+	if v, ok := os.LookupEnv("LOOPS_EXPECTED_TEAM"); ok {
+		c.ExpectedTeam = v
+	}
+	if v, ok := os.LookupEnv("LOOPS_MAX_CONCURRENCY"); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid LOOPS_MAX_CONCURRENCY: %w", err)
+		}
+		c.MaxConcurrency = n
+	}
+	if v, ok := os.LookupEnv("LOOPS_READ_ONLY"); ok {
+		readOnly, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid LOOPS_READ_ONLY: %w", err)
+		}
+		c.ReadOnly = readOnly
+	}
+	if v, ok := os.LookupEnv("LOOPS_DISABLED_OPERATIONS"); ok {
+		c.DisabledOperations = nil
+		for _, op := range strings.Split(v, ",") {
+			if op = strings.TrimSpace(op); op != "" {
+				c.DisabledOperations = append(c.DisabledOperations, Operation(op))
+			}
+		}
+	}
+	return nil
+}
+
+// ConfigWatcher periodically reloads a configuration, and applies changes to a running client where that is safe:
+// the API key, and the operations disabled by kill switches. Other changes require recreating the client, which is
+// reported to the restart hook.
+type ConfigWatcher struct {
+	client          *Client
+	interval        time.Duration
+	restartHook     func(changed []string)
+	reloadErrorHook func(err error)
+
+	mu      sync.Mutex
+	current *Config
+}
+
+// ConfigWatcherOption allows setting custom parameters during construction of a ConfigWatcher
+type ConfigWatcherOption func(*ConfigWatcher)
+
+// WithReloadInterval sets the interval between two reloads of the configuration (default: 10s)
+func WithReloadInterval(interval time.Duration) ConfigWatcherOption {
+	return func(w *ConfigWatcher) {
+		w.interval = interval
+	}
+}
+
+// WithRestartHook registers a hook that is called with the names of the changed fields, when the configuration
+// changed in a way that can't be applied to a running client.
+func WithRestartHook(hook func(changed []string)) ConfigWatcherOption {
+	return func(w *ConfigWatcher) {
+		w.restartHook = hook
+	}
+}
+
+// WithReloadErrorHook registers a hook that is called when reloading the configuration in Run fails, e.g. because
+// the file is invalid. The previous configuration stays in effect.
+func WithReloadErrorHook(hook func(err error)) ConfigWatcherOption {
+	return func(w *ConfigWatcher) {
+		w.reloadErrorHook = hook
+	}
+}
+
+// NewConfigWatcher creates a watcher applying changes of the given configuration, as loaded by LoadConfig, to a
+// client created from it. Call Run to start watching.
+func NewConfigWatcher(client *Client, config *Config, opts ...ConfigWatcherOption) *ConfigWatcher {
+	w := &ConfigWatcher{
+		client:   client,
+		interval: defaultConfigReloadInterval,
+		current:  config,
+	}
+	for _, o := range opts {
+		o(w)
+	}
+	return w
+}
+
+// Config returns the configuration currently in effect.
+func (w *ConfigWatcher) Config() *Config {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current
+}
+
+// Reload loads the configuration again, and applies its changes. If it fails to load or is invalid, the previous
+// configuration stays in effect.
+func (w *ConfigWatcher) Reload() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	config, err := LoadConfig(w.current.path)
+	if err != nil {
+		return err
+	}
+
+	if config.APIKey != w.current.APIKey {
+		w.client.SetAPIKey(config.APIKey)
+	}
+
+	var changed []string
+	if !slices.Equal(config.DisabledOperations, w.current.DisabledOperations) {
+		if w.client.killSwitches == nil {
+			changed = append(changed, "DisabledOperations")
+		} else {
+			// only toggle the operations that changed, to keep switches toggled by other means, e.g. over HTTP
+			for _, op := range w.current.DisabledOperations {
+				if !slices.Contains(config.DisabledOperations, op) {
+					w.client.killSwitches.Enable(op)
+				}
+			}
+			for _, op := range config.DisabledOperations {
+				if !slices.Contains(w.current.DisabledOperations, op) {
+					w.client.killSwitches.Disable(op)
+				}
+			}
+		}
+	}
+
+	previous, next := reflect.ValueOf(*w.current), reflect.ValueOf(*config)
+	for i := range previous.NumField() {
+		field := previous.Type().Field(i)
+		if !field.IsExported() || field.Name == "APIKey" || field.Name == "DisabledOperations" {
+			continue
+		}
+		if !reflect.DeepEqual(previous.Field(i).Interface(), next.Field(i).Interface()) {
+			changed = append(changed, field.Name)
+		}
+	}
+	if len(changed) > 0 && w.restartHook != nil {
+		w.restartHook(changed)
+	}
+
+	w.current = config
+	return nil
+}
+
+// Run reloads the configuration periodically, until ctx is done.
+func (w *ConfigWatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := w.Reload(); err != nil && w.reloadErrorHook != nil {
+			w.reloadErrorHook(err)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package loops
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadConfigYAML(t *testing.T) {
+	path := writeConfigFile(t, "loops.yaml", `
+apiUrl: https://loops.example.com/api/v1/
+apiKey: from-file
+maxConcurrency: 8
+disabledOperations: [sendEvent]
+quotas:
+  - scope: transactionalId
+    key: password-reset
+    limit: 1000
+    window: 1h
+recipientPolicy:
+  transactionalEmails: {limit: 5, window: 1h}
+  action: drop
+`)
+	t.Setenv("LOOPS_API_KEY", "from-env")
+
+	config, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "https://loops.example.com/api/v1/", config.APIURL)
+	assert.Equal(t, "from-env", config.APIKey, "environment variables override the file")
+	assert.Equal(t, 8, config.MaxConcurrency)
+	assert.Equal(t, []Operation{OperationSendEvent}, config.DisabledOperations)
+	assert.Equal(t, []Quota{{Scope: QuotaScopeTransactionalID, Key: "password-reset", Limit: 1000, Window: time.Hour}}, config.Quotas)
+	require.NotNil(t, config.RecipientPolicy)
+	assert.Equal(t, RecipientLimit{Limit: 5, Window: time.Hour}, config.RecipientPolicy.TransactionalEmails)
+	assert.Equal(t, RecipientActionDrop, config.RecipientPolicy.Action)
+
+	opts, err := config.Options()
+	require.NoError(t, err)
+	client, err := NewClient(opts...)
+	require.NoError(t, err)
+	assert.True(t, client.killSwitches.IsDisabled(OperationSendEvent))
+}
+
+func TestLoadConfigJSON(t *testing.T) {
+	path := writeConfigFile(t, "loops.json", `{"apiKey": "from-file", "readOnly": true}`)
+
+	config, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "from-file", config.APIKey)
+	assert.True(t, config.ReadOnly)
+}
+
+func TestLoadConfigEnvOnly(t *testing.T) {
+	t.Setenv("LOOPS_CONFIG_FILE", "")
+	t.Setenv("LOOPS_API_KEY", "from-env")
+	t.Setenv("LOOPS_DISABLED_OPERATIONS", "sendEvent, deleteContact")
+	t.Setenv("LOOPS_READ_ONLY", "true")
+
+	config, err := LoadConfig("")
+	require.NoError(t, err)
+	assert.Equal(t, "from-env", config.APIKey)
+	assert.Equal(t, []Operation{OperationSendEvent, OperationDeleteContact}, config.DisabledOperations)
+	assert.True(t, config.ReadOnly)
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	_, err := LoadConfig(writeConfigFile(t, "typo.yaml", "apiKeyy: secret\n"))
+	require.ErrorContains(t, err, "apiKeyy")
+
+	_, err = LoadConfig(writeConfigFile(t, "invalid.yaml", `
+apiUrl: not a url
+disabledOperations: [sendSMS]
+quotas: [{scope: eventName, limit: 0, window: 1h}]
+recipientPolicy: {action: explode}
+`))
+	require.ErrorContains(t, err, "explode")
+
+	_, err = LoadConfig(writeConfigFile(t, "invalid.yaml", `
+apiUrl: not a url
+disabledOperations: [sendSMS]
+quotas: [{scope: eventName, limit: 0, window: 1h}]
+`))
+	require.ErrorContains(t, err, "invalid api url")
+	require.ErrorContains(t, err, "unknown operation")
+	require.ErrorContains(t, err, "invalid quota")
+}
+
+func TestConfigWatcher(t *testing.T) {
+	path := writeConfigFile(t, "loops.yaml", "apiKey: old-key\nmaxConcurrency: 4\n")
+	config, err := LoadConfig(path)
+	require.NoError(t, err)
+	opts, err := config.Options()
+	require.NoError(t, err)
+
+	var authorization string
+	httpClient := httpClientFunc(func(req *http.Request) (*http.Response, error) {
+		authorization = req.Header.Get("Authorization")
+		return jsonResponse(http.StatusOK, `{"success":true,"message":""}`), nil
+	})
+	client, err := NewClient(append(opts, WithHTTPClient(httpClient))...)
+	require.NoError(t, err)
+
+	var restartRequired []string
+	watcher := NewConfigWatcher(client, config, WithRestartHook(func(changed []string) {
+		restartRequired = changed
+	}))
+
+	require.NoError(t, os.WriteFile(path, []byte("apiKey: new-key\nmaxConcurrency: 8\ndisabledOperations: [sendEvent]\n"), 0o600))
+	require.NoError(t, watcher.Reload())
+
+	require.NoError(t, client.Do(context.Background(), http.MethodGet, "/lists", nil, nil))
+	assert.Equal(t, "Bearer new-key", authorization)
+	err = client.SendEvent(context.Background(), &Event{Email: String("test@example.com"), EventName: "signup"})
+	require.ErrorIs(t, err, ErrOperationDisabled)
+	assert.Equal(t, []string{"MaxConcurrency"}, restartRequired)
+
+	// an invalid config is rejected, and the previous one stays in effect
+	require.NoError(t, os.WriteFile(path, []byte("apiKey: [\n"), 0o600))
+	require.Error(t, watcher.Reload())
+	assert.Equal(t, "new-key", watcher.Config().APIKey)
+}
+
+func TestConfigWatcherKeepsManualKillSwitches(t *testing.T) {
+	path := writeConfigFile(t, "loops.yaml", "disabledOperations: [sendEvent]\n")
+	config, err := LoadConfig(path)
+	require.NoError(t, err)
+	opts, err := config.Options()
+	require.NoError(t, err)
+	client, err := NewClient(opts...)
+	require.NoError(t, err)
+	watcher := NewConfigWatcher(client, config)
+
+	client.killSwitches.Disable(OperationDeleteContact) // e.g. toggled over HTTP during an incident
+	require.NoError(t, os.WriteFile(path, []byte("disabledOperations: []\n"), 0o600))
+	require.NoError(t, watcher.Reload())
+
+	assert.Equal(t, []Operation{OperationDeleteContact}, client.killSwitches.Disabled())
+}
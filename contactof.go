@@ -0,0 +1,139 @@
+package loops
+
+import (
+	"context"
+	"fmt"
+)
+
+// ContactOf is a contact with custom properties of type P, a struct mapped to custom properties by its `loops` tags
+// as described on MarshalProperties. It is used with CreateContactOf and FindContactOf, so custom properties are
+// typed instead of going through a map. For partial updates, see ContactUpdateOf.
+type ContactOf[P any] struct {
+	// The contact's ID.
+	ID string
+	// The contact's email address.
+	Email string
+	// The contact's first name.
+	FirstName *string
+	// The contact's last name.
+	LastName *string
+	// The source the contact was created from.
+	Source *string
+	// Whether the contact will receive campaign and loops emails.
+	Subscribed bool
+	// The contact's user group (used to segemnt users when sending emails).
+	UserGroup *string
+	// A unique user ID (for example, from an external application).
+	UserID *string
+	// Mailing lists the contact is subscribed to.
+	MailingLists map[string]bool
+	// Double opt-in status.
+	OptInStatus *OptInStatus
+	// Custom properties for the contact.
+	Properties P
+	// Issues found while decoding the contact from an API response, see DecodeWarning.
+	DecodeWarnings []DecodeWarning
+}
+
+// CreateContactOf creates a new contact with custom properties of type P. See Client.CreateContact.
+func CreateContactOf[P any](ctx context.Context, c *Client, contact *ContactOf[P]) (string, error) {
+	untyped, err := contact.toContact()
+	if err != nil {
+		return "", err
+	}
+	return c.CreateContact(ctx, untyped)
+}
+
+// ContactUpdateOf is a partial update of a contact with custom properties of type P, see ContactUpdate. Only the
+// standard fields that are set are sent. Custom properties are sent as by MarshalProperties, so all fields of P are
+// sent except zero fields tagged omitempty. To leave properties unchanged unless set, use pointer fields tagged
+// omitempty, e.g. `loops:"seats,omitempty"` on a *int: nil leaves the property unchanged, and a pointer to 0 sets it
+// to 0.
+type ContactUpdateOf[P any] struct {
+	// The contact's email address. If the contact is identified by UserID, this updates its email address.
+	Email string
+	// The contact's unique user ID. If Email is set as well, this updates its user ID.
+	UserID string
+	// The contact's first name.
+	FirstName Optional[string]
+	// The contact's last name.
+	LastName Optional[string]
+	// The source the contact was created from.
+	Source Optional[string]
+	// Whether the contact will receive campaign and loops emails.
+	Subscribed Optional[bool]
+	// The contact's user group (used to segemnt users when sending emails).
+	UserGroup Optional[string]
+	// Mailing lists to subscribe the contact to (true) or unsubscribe it from (false). Lists not included are left
+	// unchanged.
+	MailingLists map[string]bool
+	// Custom properties to update. Zero fields tagged omitempty are left unchanged.
+	Properties P
+}
+
+// UpdateContactOf updates or creates a contact with custom properties of type P, only sending the standard fields that
+// are set. See ContactUpdateOf and Client.UpdateContactFields.
+func UpdateContactOf[P any](ctx context.Context, c *Client, update *ContactUpdateOf[P]) (string, error) {
+	properties, err := MarshalProperties(&update.Properties)
+	if err != nil {
+		return "", err
+	}
+	return c.UpdateContactFields(ctx, &ContactUpdate{
+		Email:        update.Email,
+		UserID:       update.UserID,
+		FirstName:    update.FirstName,
+		LastName:     update.LastName,
+		Source:       update.Source,
+		Subscribed:   update.Subscribed,
+		UserGroup:    update.UserGroup,
+		MailingLists: update.MailingLists,
+		Properties:   properties,
+	})
+}
+
+// FindContactOf finds a contact by email or userId, decoding its custom properties into P. Custom properties not
+// mapped to a field of P are ignored. See Client.FindContact.
+func FindContactOf[P any](ctx context.Context, c *Client, contact *ContactIdentifier) (*ContactOf[P], error) {
+	untyped, err := c.FindContact(ctx, contact)
+	if err != nil {
+		return nil, err
+	}
+	typed := &ContactOf[P]{
+		ID:             untyped.ID,
+		Email:          untyped.Email,
+		FirstName:      untyped.FirstName,
+		LastName:       untyped.LastName,
+		Source:         untyped.Source,
+		Subscribed:     untyped.Subscribed,
+		UserGroup:      untyped.UserGroup,
+		UserID:         untyped.UserID,
+		MailingLists:   untyped.MailingLists,
+		OptInStatus:    untyped.OptInStatus,
+		DecodeWarnings: untyped.DecodeWarnings,
+	}
+	if err := UnmarshalProperties(untyped.Properties, &typed.Properties); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+	return typed, nil
+}
+
+// toContact converts the contact to a Contact with untyped custom properties.
+func (c *ContactOf[P]) toContact() (*Contact, error) {
+	properties, err := MarshalProperties(&c.Properties)
+	if err != nil {
+		return nil, err
+	}
+	return &Contact{
+		ID:           c.ID,
+		Email:        c.Email,
+		FirstName:    c.FirstName,
+		LastName:     c.LastName,
+		Source:       c.Source,
+		Subscribed:   c.Subscribed,
+		UserGroup:    c.UserGroup,
+		UserID:       c.UserID,
+		MailingLists: c.MailingLists,
+		OptInStatus:  c.OptInStatus,
+		Properties:   properties,
+	}, nil
+}
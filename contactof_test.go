@@ -0,0 +1,77 @@
+package loops
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type developerProperties struct {
+	CompanyRole string `loops:"companyRole"`
+}
+
+func TestCreateContactOf(t *testing.T) {
+	client := newReplayTestClient(t, "create-contact.replay.json")
+	contactID, err := CreateContactOf(context.Background(), client, &ContactOf[developerProperties]{
+		Email:      "test@example.com",
+		FirstName:  String("Test"),
+		LastName:   String("User"),
+		UserID:     String("user_123"),
+		Subscribed: true,
+		Properties: developerProperties{CompanyRole: "Developer"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "cmk6vyub00c7b0i04dlregeit", contactID)
+}
+
+func TestFindContactOf(t *testing.T) {
+	client := newReplayTestClient(t, "find-contact.replay.json")
+	contact, err := FindContactOf[developerProperties](context.Background(), client, &ContactIdentifier{
+		Email: String("new-test-mail@example.com"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "cmk6vyub00c7b0i04dlregeit", contact.ID)
+	assert.Equal(t, "user_123", *contact.UserID)
+	assert.Equal(t, developerProperties{CompanyRole: "Developer"}, contact.Properties)
+}
+
+func TestUpdateContactOf(t *testing.T) {
+	httpClient := &recordingHTTPClient{}
+	client, err := NewClient(WithHTTPClient(httpClient))
+	require.NoError(t, err)
+
+	type properties struct {
+		CompanyRole string `loops:"companyRole"`
+		Team        string `loops:"team,omitempty"`
+		Seniority   *int   `loops:"seniority,omitempty"`
+		Mentor      *bool  `loops:"mentor,omitempty"`
+		Remote      *bool  `loops:"remote"`
+	}
+	mentor := false
+	contactID, err := UpdateContactOf(context.Background(), client, &ContactUpdateOf[properties]{
+		Email:      "test@example.com",
+		UserGroup:  Set("Developers"),
+		Properties: properties{Mentor: &mentor},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "123", contactID)
+	require.Len(t, httpClient.bodies, 1)
+	assert.JSONEq(t, `{"email":"test@example.com","userGroup":"Developers","companyRole":"","mentor":false,"remote":null}`,
+		httpClient.bodies[0], "unset standard fields and zero properties tagged omitempty are not sent")
+}
+
+func TestFindContactOfMismatch(t *testing.T) {
+	httpClient := httpClientFunc(func(*http.Request) (*http.Response, error) {
+		return jsonResponse(http.StatusOK, `[{"id":"123","email":"test@example.com","subscribed":true,"companyRole":5}]`), nil
+	})
+	client, err := NewClient(WithHTTPClient(httpClient))
+	require.NoError(t, err)
+
+	_, err = FindContactOf[developerProperties](context.Background(), client, &ContactIdentifier{
+		Email: String("test@example.com"),
+	})
+	require.ErrorIs(t, err, ErrPropertyMismatch)
+}
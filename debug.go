@@ -0,0 +1,164 @@
+package loops
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// recentErrorsSize is the number of most recent errors kept for debugging.
+const recentErrorsSize = 20
+
+// DebugInfo is a snapshot of the live state of a client, for diagnosing issues in production.
+type DebugInfo struct {
+	// The API URL the client sends requests to.
+	APIURL string `json:"apiUrl"`
+	// Whether the client has been closed.
+	Closed bool `json:"closed"`
+	// The number of requests currently in flight.
+	InFlight int `json:"inFlight"`
+	// The usage of the concurrency limits, nil if no limit is configured.
+	Concurrency *ConcurrencyInfo `json:"concurrency,omitempty"`
+	// The rate limit as reported by the most recent response of the Loops API, nil if none reported it yet.
+	RateLimit *RateLimitInfo `json:"rateLimit,omitempty"`
+	// The operations currently disabled by a kill switch.
+	DisabledOperations []Operation `json:"disabledOperations,omitempty"`
+	// Call counters per operation, for all operations called at least once.
+	Operations map[Operation]OperationStats `json:"operations"`
+	// The most recent errors, oldest first.
+	RecentErrors []RecentError `json:"recentErrors"`
+}
+
+// ConcurrencyInfo is the usage of the concurrency limits of a client. Reads and writes share the same numbers if
+// only a shared limit is configured. A limit of zero means no limit.
+type ConcurrencyInfo struct {
+	ReadsInUse  int `json:"readsInUse"`
+	ReadLimit   int `json:"readLimit"`
+	WritesInUse int `json:"writesInUse"`
+	WriteLimit  int `json:"writeLimit"`
+}
+
+// RateLimitInfo is the rate limit reported by the Loops API in the X-RateLimit-* headers of a response.
+type RateLimitInfo struct {
+	Limit      int       `json:"limit"`
+	Remaining  int       `json:"remaining"`
+	ObservedAt time.Time `json:"observedAt"`
+}
+
+// OperationStats counts the calls of an operation.
+type OperationStats struct {
+	// The number of calls, including failed ones.
+	Calls int64 `json:"calls"`
+	// The number of calls that failed.
+	Errors int64 `json:"errors"`
+	// The time of the most recent call.
+	LastCallAt time.Time `json:"lastCallAt"`
+}
+
+// RecentError is an error returned by a call of the client.
+type RecentError struct {
+	Operation Operation `json:"operation"`
+	Error     string    `json:"error"`
+	At        time.Time `json:"at"`
+}
+
+// DebugInfo returns a snapshot of the live state of the client.
+func (c *Client) DebugInfo() DebugInfo {
+	info := c.stats.snapshot()
+	info.APIURL = c.apiURL.String()
+	info.Closed, info.InFlight = c.lifecycle.state()
+	if c.limits.reads != nil || c.limits.writes != nil {
+		info.Concurrency = &ConcurrencyInfo{
+			ReadsInUse:  len(c.limits.reads),
+			ReadLimit:   cap(c.limits.reads),
+			WritesInUse: len(c.limits.writes),
+			WriteLimit:  cap(c.limits.writes),
+		}
+	}
+	if c.killSwitches != nil {
+		info.DisabledOperations = c.killSwitches.Disabled()
+	}
+	return info
+}
+
+// DebugHandler returns an http.Handler responding with the live state of the client as JSON, see DebugInfo.
+// It doesn't perform any authentication, so it should only be exposed on an internal port.
+func (c *Client) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(c.DebugInfo())
+	})
+}
+
+// PublishExpvar publishes the live state of the client as expvar variable with the given name, so it shows up on
+// /debug/vars. Like expvar.Publish, it panics if a variable with the name is already published.
+func (c *Client) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() any { return c.DebugInfo() }))
+}
+
+// clientStats collects call counters, recent errors and the rate limit view of a client.
+type clientStats struct {
+	mu           sync.Mutex
+	operations   map[Operation]OperationStats
+	recentErrors []RecentError
+	rateLimit    *RateLimitInfo
+}
+
+func newClientStats() *clientStats {
+	return &clientStats{operations: make(map[Operation]OperationStats)}
+}
+
+// record counts a call of the given operation, and keeps its error if it failed.
+func (s *clientStats) record(op Operation, err error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := s.operations[op]
+	stats.Calls++
+	stats.LastCallAt = now
+	if err != nil {
+		stats.Errors++
+		if len(s.recentErrors) == recentErrorsSize {
+			s.recentErrors = append(s.recentErrors[:0], s.recentErrors[1:]...)
+		}
+		s.recentErrors = append(s.recentErrors, RecentError{Operation: op, Error: err.Error(), At: now})
+	}
+	s.operations[op] = stats
+}
+
+// observeRateLimit remembers the rate limit reported in the headers of a response, if any.
+func (s *clientStats) observeRateLimit(header http.Header) {
+	limit, err := strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	if err != nil {
+		return
+	}
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rateLimit = &RateLimitInfo{Limit: limit, Remaining: remaining, ObservedAt: time.Now()}
+}
+
+func (s *clientStats) snapshot() DebugInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info := DebugInfo{
+		Operations:   make(map[Operation]OperationStats, len(s.operations)),
+		RecentErrors: append([]RecentError{}, s.recentErrors...),
+	}
+	for op, stats := range s.operations {
+		info.Operations[op] = stats
+	}
+	if s.rateLimit != nil {
+		rateLimit := *s.rateLimit
+		info.RateLimit = &rateLimit
+	}
+	return info
+}
@@ -0,0 +1,87 @@
+package loops
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugInfo(t *testing.T) {
+	httpClient := httpClientFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == "/api/v1/contacts/delete" {
+			return jsonResponse(http.StatusNotFound, `{"success":false,"message":"Contact not found"}`), nil
+		}
+		resp := jsonResponse(http.StatusOK, `{"success":true,"message":""}`)
+		resp.Header.Set("X-RateLimit-Limit", "10")
+		resp.Header.Set("X-RateLimit-Remaining", "7")
+		return resp, nil
+	})
+	killSwitches := NewKillSwitches()
+	killSwitches.Disable(OperationCreateContact)
+	client, err := NewClient(WithHTTPClient(httpClient), WithMaxReadConcurrency(4), WithKillSwitches(killSwitches))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	require.NoError(t, client.SendEvent(ctx, &Event{Email: String("test@example.com"), EventName: "signup"}))
+	require.NoError(t, client.SendEvent(ctx, &Event{Email: String("test@example.com"), EventName: "signup"}))
+	require.Error(t, client.DeleteContact(ctx, &ContactIdentifier{Email: String("test@example.com")}))
+
+	info := client.DebugInfo()
+	assert.Equal(t, "https://app.loops.so/api/v1/", info.APIURL)
+	assert.False(t, info.Closed)
+	assert.Equal(t, 0, info.InFlight)
+	assert.Equal(t, &ConcurrencyInfo{ReadLimit: 4}, info.Concurrency)
+	require.NotNil(t, info.RateLimit)
+	assert.Equal(t, 10, info.RateLimit.Limit)
+	assert.Equal(t, 7, info.RateLimit.Remaining)
+	assert.Equal(t, []Operation{OperationCreateContact}, info.DisabledOperations)
+	assert.Equal(t, int64(2), info.Operations[OperationSendEvent].Calls)
+	assert.Equal(t, int64(0), info.Operations[OperationSendEvent].Errors)
+	assert.Equal(t, int64(1), info.Operations[OperationDeleteContact].Errors)
+	require.Len(t, info.RecentErrors, 1)
+	assert.Equal(t, "Contact not found", info.RecentErrors[0].Error)
+}
+
+func TestDebugInfoKeepsRecentErrors(t *testing.T) {
+	client, err := NewClient(WithHTTPClient(httpClientFunc(func(*http.Request) (*http.Response, error) {
+		return jsonResponse(http.StatusInternalServerError, `{"message":"boom"}`), nil
+	})))
+	require.NoError(t, err)
+
+	for range recentErrorsSize + 5 {
+		_ = client.Do(context.Background(), http.MethodGet, "/lists", nil, nil)
+	}
+	info := client.DebugInfo()
+	assert.Len(t, info.RecentErrors, recentErrorsSize)
+	assert.Equal(t, int64(recentErrorsSize+5), info.Operations[OperationRawRead].Errors)
+}
+
+// expvarRuns counts the runs of TestDebugHandler, to publish a unique expvar name in each.
+var expvarRuns atomic.Int64
+
+func TestDebugHandler(t *testing.T) {
+	client, err := NewClient(WithHTTPClient(&recordingHTTPClient{}))
+	require.NoError(t, err)
+	_, err = client.CreateContact(context.Background(), &Contact{Email: "test@example.com"})
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	client.DebugHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/loops", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	info := DebugInfo{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &info))
+	assert.Equal(t, int64(1), info.Operations[OperationCreateContact].Calls)
+
+	// expvar names are process-wide and can't be unpublished, so use a new one for every run, e.g. with -count
+	name := fmt.Sprintf("%s_%d", t.Name(), expvarRuns.Add(1))
+	client.PublishExpvar(name)
+	assert.Contains(t, expvar.Get(name).String(), `"createContact"`)
+}
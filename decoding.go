@@ -0,0 +1,84 @@
+package loops
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// DecodeWarning is an issue found while decoding a contact from an API response, that didn't fail decoding.
+type DecodeWarning struct {
+	// The field the issue was found in, e.g. "email" or "mailingLists.<list id>".
+	Field string
+	// A description of the issue.
+	Issue string
+}
+
+// String returns the warning in the form "field: issue".
+func (w DecodeWarning) String() string {
+	return fmt.Sprintf("%s: %s", w.Field, w.Issue)
+}
+
+// decodeOptions configure how contacts are decoded from API responses.
+type decodeOptions struct {
+	lenient      bool
+	exactNumbers bool
+	// the names of the custom properties of type date, which are decoded as time.Time
+	dateProperties map[string]bool
+}
+
+// isDefault returns whether the options are the ones Contact.UnmarshalJSON uses.
+func (o decodeOptions) isDefault() bool {
+	return !o.lenient && !o.exactNumbers && len(o.dateProperties) == 0
+}
+
+// WithLenientDecoding makes the client decode contacts leniently: standard fields that are missing or invalid,
+// such as a missing email or a mailing list subscription status that is not a boolean, are recorded in
+// Contact.DecodeWarnings instead of failing the call.
+func WithLenientDecoding() ClientOption {
+	return func(c *clientConfig) {
+		c.decodeOptions.lenient = true
+	}
+}
+
+// WithExactNumbers makes the client decode numbers in custom contact properties as json.Number instead of float64,
+// preserving their exact textual form, e.g. for large integer IDs that don't fit into a float64.
+func WithExactNumbers() ClientOption {
+	return func(c *clientConfig) {
+		c.decodeOptions.exactNumbers = true
+	}
+}
+
+// WithPropertySchema makes the client decode custom contact properties according to their type in the given schema,
+// as returned by GetContactProperties: values of date properties are decoded as time.Time.
+func WithPropertySchema(schema []*ContactProperty) ClientOption {
+	return func(c *clientConfig) {
+		c.decodeOptions.dateProperties = make(map[string]bool)
+		for _, property := range schema {
+			if property.Type == PropertyTypeDate {
+				c.decodeOptions.dateProperties[property.Key] = true
+			}
+		}
+	}
+}
+
+// findContacts calls the find contact endpoint, decoding the contacts with the decode options of the client.
+func (c *Client) findContacts(ctx context.Context, contact *ContactIdentifier, params url.Values) ([]*Contact, error) {
+	if c.decodeOptions.isDefault() {
+		return invoke[[]*Contact](ctx, c, OperationFindContact, contact, params)
+	}
+
+	raw, err := invoke[[]json.RawMessage](ctx, c, OperationFindContact, contact, params)
+	if err != nil {
+		return nil, err
+	}
+	contacts := make([]*Contact, len(raw))
+	for i, data := range raw {
+		contacts[i] = &Contact{}
+		if err := contacts[i].decode(data, c.decodeOptions); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+		}
+	}
+	return contacts, nil
+}
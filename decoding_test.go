@@ -0,0 +1,84 @@
+package loops
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindContactLenientDecoding(t *testing.T) {
+	httpClient := httpClientFunc(func(*http.Request) (*http.Response, error) {
+		return jsonResponse(http.StatusOK, `[{"id":"123","email":"test@example.com","mailingLists":{"list_1":null}}]`), nil
+	})
+	identifier := &ContactIdentifier{Email: String("test@example.com")}
+
+	strict, err := NewClient(WithHTTPClient(httpClient))
+	require.NoError(t, err)
+	_, err = strict.FindContact(context.Background(), identifier)
+	require.ErrorContains(t, err, "not a boolean")
+
+	lenient, err := NewClient(WithHTTPClient(httpClient), WithLenientDecoding())
+	require.NoError(t, err)
+	contact, err := lenient.FindContact(context.Background(), identifier)
+	require.NoError(t, err)
+	assert.Equal(t, "test@example.com", contact.Email)
+	assert.Empty(t, contact.MailingLists)
+	assert.Equal(t, []DecodeWarning{
+		{Field: "mailingLists.list_1", Issue: "subscription status is not a boolean, list skipped"},
+		{Field: "subscribed", Issue: "missing or invalid"},
+	}, contact.DecodeWarnings)
+}
+
+func TestFindContactExactNumbers(t *testing.T) {
+	httpClient := httpClientFunc(func(*http.Request) (*http.Response, error) {
+		return jsonResponse(http.StatusOK, `[{"id":"123","email":"test@example.com","subscribed":true,
+			"accountId":9007199254740993,"scores":[1.10]}]`), nil
+	})
+	client, err := NewClient(WithHTTPClient(httpClient), WithExactNumbers())
+	require.NoError(t, err)
+
+	contact, err := client.FindContact(context.Background(), &ContactIdentifier{Email: String("test@example.com")})
+	require.NoError(t, err)
+	assert.Equal(t, json.Number("9007199254740993"), contact.Properties["accountId"])
+	assert.Equal(t, []any{json.Number("1.10")}, contact.Properties["scores"])
+	accountID, err := contact.Properties.Int64("accountId")
+	require.NoError(t, err)
+	assert.Equal(t, int64(9007199254740993), accountID)
+}
+
+func TestFindContactPropertySchema(t *testing.T) {
+	body := `[{"id":"123","email":"test@example.com","subscribed":true,"createdAt":"2024-11-19T12:24:35.101Z",
+		"launchedAt":-14552880000,"landedAt":"1969-07-20T20:17:40Z","role":"1969-07-16","returnedAt":"soon"}]`
+	httpClient := httpClientFunc(func(*http.Request) (*http.Response, error) {
+		return jsonResponse(http.StatusOK, body), nil
+	})
+	schema := []*ContactProperty{
+		{Key: "createdAt", Type: PropertyTypeDate},
+		{Key: "launchedAt", Type: PropertyTypeDate},
+		{Key: "landedAt", Type: PropertyTypeDate},
+		{Key: "returnedAt", Type: PropertyTypeDate},
+		{Key: "role", Type: PropertyTypeString},
+	}
+	identifier := &ContactIdentifier{Email: String("test@example.com")}
+
+	client, err := NewClient(WithHTTPClient(httpClient), WithPropertySchema(schema))
+	require.NoError(t, err)
+	_, err = client.FindContact(context.Background(), identifier)
+	require.ErrorIs(t, err, ErrPropertyMismatch, "returnedAt is no valid date")
+
+	client, err = NewClient(WithHTTPClient(httpClient), WithPropertySchema(schema), WithLenientDecoding())
+	require.NoError(t, err)
+	contact, err := client.FindContact(context.Background(), identifier)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 11, 19, 12, 24, 35, 101_000_000, time.UTC), contact.Properties["createdAt"])
+	assert.Equal(t, time.Date(1969, 7, 16, 13, 32, 0, 0, time.UTC), contact.Properties["launchedAt"])
+	assert.Equal(t, time.Date(1969, 7, 20, 20, 17, 40, 0, time.UTC), contact.Properties["landedAt"])
+	assert.Equal(t, "1969-07-16", contact.Properties["role"], "only date properties are decoded as time.Time")
+	assert.Equal(t, "soon", contact.Properties["returnedAt"])
+	assert.Equal(t, []DecodeWarning{{Field: "returnedAt", Issue: "invalid date, decoded as is"}}, contact.DecodeWarnings)
+}
@@ -0,0 +1,447 @@
+package loops
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// The helpers in this file encode and decode JSON objects field by field, without going through an intermediate
+// map[string]any. Their output is byte for byte identical to encoding/json, so request bodies don't change, with one
+// exception: time.Time values are encoded in the Loops date format, unix milliseconds, rather than as RFC 3339 string.
+
+// jsonAppender is implemented by types that can append their JSON encoding to a byte slice, avoiding the
+// intermediate allocations of json.Marshal.
+type jsonAppender interface {
+	appendJSON(dst []byte) ([]byte, error)
+}
+
+var bufferPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 0, 512)
+		return &buf
+	},
+}
+
+var keysPool = sync.Pool{
+	New: func() any {
+		keys := make([]string, 0, 16)
+		return &keys
+	},
+}
+
+// marshalAppender encodes v into a pooled buffer, and returns a copy of exactly the encoded size.
+func marshalAppender(v jsonAppender) ([]byte, error) {
+	buf := bufferPool.Get().(*[]byte) //nolint:forcetypeassert // the pool only contains *[]byte
+	defer bufferPool.Put(buf)
+
+	b, err := v.appendJSON((*buf)[:0])
+	if err != nil {
+		return nil, err
+	}
+	*buf = b
+	return bytes.Clone(b), nil
+}
+
+// sortedKeys returns the keys of m in sorted order, in a pooled slice which must be returned using putKeys.
+func sortedKeys[V any](m map[string]V) *[]string {
+	keys := keysPool.Get().(*[]string) //nolint:forcetypeassert // the pool only contains *[]string
+	*keys = (*keys)[:0]
+	for k := range m {
+		*keys = append(*keys, k)
+	}
+	slices.Sort(*keys)
+	return keys
+}
+
+func putKeys(keys *[]string) {
+	clear(*keys)
+	keysPool.Put(keys)
+}
+
+const hex = "0123456789abcdef"
+
+// appendJSONString appends s as JSON string, escaped the same way as encoding/json does (including HTML characters).
+func appendJSONString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch b {
+			case '\\', '"':
+				dst = append(dst, '\\', b)
+			case '\b':
+				dst = append(dst, '\\', 'b')
+			case '\f':
+				dst = append(dst, '\\', 'f')
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hex[b>>4], hex[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		c, size := utf8.DecodeRuneInString(s[i:])
+		if c == utf8.RuneError && size == 1 {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, `\ufffd`...)
+			i += size
+			start = i
+			continue
+		}
+		if c == '\u2028' || c == '\u2029' {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hex[c&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
+
+// appendJSONFloat appends f formatted the same way as encoding/json does.
+func appendJSONFloat(dst []byte, f float64, bits int) ([]byte, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, fmt.Errorf("unsupported float value: %s", strconv.FormatFloat(f, 'g', -1, bits))
+	}
+	abs := math.Abs(f)
+	format := byte('f')
+	if abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	dst = strconv.AppendFloat(dst, f, format, -1, bits)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		n := len(dst)
+		if n >= 4 && dst[n-4] == 'e' && dst[n-3] == '-' && dst[n-2] == '0' {
+			dst[n-2] = dst[n-1]
+			dst = dst[:n-1]
+		}
+	}
+	return dst, nil
+}
+
+// appendJSONValue appends the JSON encoding of v. Common property value types are encoded directly,
+// everything else falls back to encoding/json.
+func appendJSONValue(dst []byte, v any) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(dst, "null"...), nil
+	case string:
+		return appendJSONString(dst, v), nil
+	case bool:
+		return strconv.AppendBool(dst, v), nil
+	case float64:
+		return appendJSONFloat(dst, v, 64)
+	case float32:
+		return appendJSONFloat(dst, float64(v), 32)
+	case int:
+		return strconv.AppendInt(dst, int64(v), 10), nil
+	case int64:
+		return strconv.AppendInt(dst, v, 10), nil
+	case int32:
+		return strconv.AppendInt(dst, int64(v), 10), nil
+	case uint:
+		return strconv.AppendUint(dst, uint64(v), 10), nil
+	case uint64:
+		return strconv.AppendUint(dst, v, 10), nil
+	case uint32:
+		return strconv.AppendUint(dst, uint64(v), 10), nil
+	case map[string]bool:
+		return appendJSONBoolMap(dst, v), nil
+	case time.Time:
+		return strconv.AppendInt(dst, v.UnixMilli(), 10), nil // the Loops date format
+	case jsonAppender:
+		return v.appendJSON(dst)
+	}
+
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(dst, buf...), nil
+}
+
+// appendJSONBoolMap appends m as JSON object with sorted keys.
+func appendJSONBoolMap(dst []byte, m map[string]bool) []byte {
+	if m == nil {
+		return append(dst, "null"...)
+	}
+	keys := sortedKeys(m)
+	defer putKeys(keys)
+
+	dst = append(dst, '{')
+	for i, k := range *keys {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = appendJSONString(dst, k)
+		dst = append(dst, ':')
+		dst = strconv.AppendBool(dst, m[k])
+	}
+	return append(dst, '}')
+}
+
+// objectWriter appends the fields of a JSON object one by one.
+type objectWriter struct {
+	buf    []byte
+	fields int
+}
+
+func newObjectWriter(dst []byte) objectWriter {
+	return objectWriter{buf: append(dst, '{')}
+}
+
+func (w *objectWriter) key(k string) {
+	if w.fields > 0 {
+		w.buf = append(w.buf, ',')
+	}
+	w.fields++
+	w.buf = appendJSONString(w.buf, k)
+	w.buf = append(w.buf, ':')
+}
+
+func (w *objectWriter) string(k, v string) {
+	w.key(k)
+	w.buf = appendJSONString(w.buf, v)
+}
+
+func (w *objectWriter) bool(k string, v bool) {
+	w.key(k)
+	w.buf = strconv.AppendBool(w.buf, v)
+}
+
+func (w *objectWriter) value(k string, v any) error {
+	w.key(k)
+	buf, err := appendJSONValue(w.buf, v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %q: %w", k, err)
+	}
+	w.buf = buf
+	return nil
+}
+
+func (w *objectWriter) close() []byte {
+	return append(w.buf, '}')
+}
+
+// appendObjectWithProperties appends a JSON object with standard fields and custom properties merged in sorted order.
+// fields are the names of the standard fields in sorted order, each appended by appendField if it is set.
+// Callers reject custom properties named like a standard field beforehand, see checkReservedProperties.
+func appendObjectWithProperties(dst []byte, fields []string, appendField func(w *objectWriter, name string) error,
+	properties map[string]any,
+) ([]byte, error) {
+	keys := sortedKeys(properties)
+	defer putKeys(keys)
+	names := *keys
+
+	w := newObjectWriter(dst)
+	for len(fields) > 0 || len(names) > 0 {
+		if len(names) > 0 && (len(fields) == 0 || names[0] <= fields[0]) {
+			if len(fields) > 0 && names[0] == fields[0] {
+				fields = fields[1:]
+			}
+			if err := w.value(names[0], properties[names[0]]); err != nil {
+				return nil, err
+			}
+			names = names[1:]
+			continue
+		}
+		if err := appendField(&w, fields[0]); err != nil {
+			return nil, err
+		}
+		fields = fields[1:]
+	}
+	return w.close(), nil
+}
+
+var errNotAnObject = errors.New("expected a JSON object")
+
+// eachObjectField calls fn for every field of the JSON object in data, with the raw (still encoded) key and value.
+// data must be valid JSON.
+func eachObjectField(data []byte, fn func(key, value []byte) error) error {
+	i := skipWhitespace(data, 0)
+	if i >= len(data) || data[i] != '{' {
+		return errNotAnObject
+	}
+	i = skipWhitespace(data, i+1)
+	if i < len(data) && data[i] == '}' {
+		return nil
+	}
+
+	for i < len(data) {
+		keyEnd := skipString(data, i)
+		key := data[i:keyEnd]
+		i = skipWhitespace(data, keyEnd)
+		i = skipWhitespace(data, i+1) // skip the ':'
+		valueEnd := skipValue(data, i)
+		if err := fn(key, data[i:valueEnd]); err != nil {
+			return err
+		}
+		i = skipWhitespace(data, valueEnd)
+		if i >= len(data) || data[i] == '}' {
+			return nil
+		}
+		i = skipWhitespace(data, i+1) // skip the ','
+	}
+	return nil
+}
+
+func skipWhitespace(data []byte, i int) int {
+	for i < len(data) {
+		switch data[i] {
+		case ' ', '\t', '\n', '\r':
+			i++
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// skipString returns the index right after the JSON string starting at data[i].
+func skipString(data []byte, i int) int {
+	for i++; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return i
+}
+
+// skipValue returns the index right after the JSON value starting at data[i].
+func skipValue(data []byte, i int) int {
+	if i >= len(data) {
+		return i
+	}
+	switch data[i] {
+	case '"':
+		return skipString(data, i)
+	case '{', '[':
+		depth := 0
+		for ; i < len(data); i++ {
+			switch data[i] {
+			case '"':
+				i = skipString(data, i) - 1
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return i + 1
+				}
+			}
+		}
+		return i
+	default: // number, true, false or null
+		for ; i < len(data); i++ {
+			switch data[i] {
+			case ',', '}', ']', ' ', '\t', '\n', '\r':
+				return i
+			}
+		}
+		return i
+	}
+}
+
+// decodeJSONString decodes a raw JSON string value. ok is false if raw is not a string.
+func decodeJSONString(raw []byte) (string, bool) {
+	if len(raw) < 2 || raw[0] != '"' {
+		return "", false
+	}
+	if bytes.IndexByte(raw, '\\') < 0 && utf8.Valid(raw) {
+		return string(raw[1 : len(raw)-1]), true
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return "", false
+	}
+	return s, true
+}
+
+// decodeJSONKey decodes a raw JSON object key. If it doesn't contain any escape sequences, the returned slice
+// references raw, so no allocation is needed to compare it.
+func decodeJSONKey(raw []byte) []byte {
+	if bytes.IndexByte(raw, '\\') < 0 {
+		return raw[1 : len(raw)-1]
+	}
+	key, _ := decodeJSONString(raw)
+	return []byte(key)
+}
+
+// isJSONNull returns whether the raw JSON value is null.
+func isJSONNull(raw []byte) bool {
+	return string(raw) == "null"
+}
+
+// decodeJSONBool decodes a raw JSON boolean value. ok is false if raw is not a boolean.
+func decodeJSONBool(raw []byte) (bool, bool) {
+	switch string(raw) {
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	}
+	return false, false
+}
+
+// decodeJSONValue decodes a raw JSON value the same way json.Unmarshal does into an any. If exactNumbers is set,
+// numbers are decoded as json.Number, like a json.Decoder with UseNumber does.
+func decodeJSONValue(raw []byte, exactNumbers bool) (any, error) {
+	switch raw[0] {
+	case 'n':
+		return nil, nil
+	case 't', 'f':
+		b, _ := decodeJSONBool(raw)
+		return b, nil
+	case '"':
+		if s, ok := decodeJSONString(raw); ok {
+			return s, nil
+		}
+	case '{', '[':
+	default:
+		if exactNumbers {
+			return json.Number(raw), nil
+		}
+		f, err := strconv.ParseFloat(string(raw), 64)
+		if err == nil {
+			return f, nil
+		}
+	}
+
+	var v any
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	if exactNumbers {
+		decoder.UseNumber()
+	}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
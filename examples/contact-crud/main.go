@@ -46,10 +46,10 @@ func main() {
 	}
 	slog.Info("Found contact", slog.String("id", contact.ID), slog.String("email", contact.Email))
 
-	// update a contact, specify a user group
-	_, err = client.UpdateContact(ctx, &loops.Contact{
+	// update a contact, specify a user group and leave all other fields unchanged
+	_, err = client.UpdateContactFields(ctx, &loops.ContactUpdate{
 		Email:     "neil.armstrong@moon.space",
-		UserGroup: loops.String("Astronauts"),
+		UserGroup: loops.Set("Astronauts"),
 	})
 	if err != nil {
 		slog.Error("failed to update contact", slog.Any("error", err.Error()))
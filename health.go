@@ -0,0 +1,164 @@
+package loops
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHealthCheckInterval = 30 * time.Second
+	defaultHealthCheckTimeout  = 5 * time.Second
+)
+
+// errNotChecked is reported as health check error as long as no check has completed yet.
+var errNotChecked = errors.New("no health check has completed yet")
+
+// HealthStatus is the result of the most recent health check.
+type HealthStatus struct {
+	// Whether the API key is valid and Loops is reachable.
+	Healthy bool `json:"healthy"`
+	// The name of the team the API key belongs to.
+	TeamName string `json:"teamName,omitempty"`
+	// The error of the most recent check, or nil if it succeeded.
+	Err error `json:"-"`
+	// The time the most recent check completed, or the zero time if no check has completed yet.
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// MarshalJSON adds the error message of the most recent check to the JSON representation of the status
+func (s HealthStatus) MarshalJSON() ([]byte, error) {
+	type status HealthStatus // avoid infinite recursion
+	out := struct {
+		status
+		LastError string `json:"lastError,omitempty"`
+	}{status: status(s)}
+	if s.Err != nil {
+		out.LastError = s.Err.Error()
+	}
+	return json.Marshal(out)
+}
+
+// HealthChecker periodically verifies the API key of a client using TestAPIKey, and caches the result.
+// It exposes http.Handlers for readiness and liveness endpoints, and Check for gating startup.
+type HealthChecker struct {
+	client   *Client
+	interval time.Duration
+	timeout  time.Duration
+
+	mu     sync.RWMutex
+	status HealthStatus
+}
+
+// HealthCheckerOption allows setting custom parameters during construction of a HealthChecker
+type HealthCheckerOption func(*HealthChecker)
+
+// WithCheckInterval sets the interval between two health checks (default: 30s)
+func WithCheckInterval(interval time.Duration) HealthCheckerOption {
+	return func(h *HealthChecker) {
+		h.interval = interval
+	}
+}
+
+// WithCheckTimeout sets the timeout of a single health check (default: 5s)
+func WithCheckTimeout(timeout time.Duration) HealthCheckerOption {
+	return func(h *HealthChecker) {
+		h.timeout = timeout
+	}
+}
+
+// NewHealthChecker creates a new health checker for the given client.
+// Call Run to start checking periodically.
+func NewHealthChecker(client *Client, opts ...HealthCheckerOption) *HealthChecker {
+	h := &HealthChecker{
+		client:   client,
+		interval: defaultHealthCheckInterval,
+		timeout:  defaultHealthCheckTimeout,
+		status:   HealthStatus{Err: errNotChecked},
+	}
+	for _, o := range opts {
+		o(h)
+	}
+	return h
+}
+
+// Check runs a health check right away, caches and returns its result. It can be used to gate startup on a
+// valid API key.
+func (h *HealthChecker) Check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	status := HealthStatus{CheckedAt: time.Now()}
+	info, err := h.client.TestAPIKey(ctx)
+	switch {
+	case err != nil:
+		status.Err = err
+	case !info.Success:
+		status.Err = errors.New("api key check was not successful")
+	default:
+		status.Healthy = true
+		status.TeamName = info.TeamName
+	}
+
+	h.mu.Lock()
+	h.status = status
+	h.mu.Unlock()
+	return status.Err
+}
+
+// Run checks the health periodically, until ctx is done.
+func (h *HealthChecker) Run(ctx context.Context) {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for {
+		_ = h.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Status returns the result of the most recent health check.
+func (h *HealthChecker) Status() HealthStatus {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.status
+}
+
+// ReadinessHandler returns an http.Handler reporting whether the most recent health check succeeded.
+// It responds with 200 OK if it did, and 503 Service Unavailable otherwise, both with the status as JSON body.
+func (h *HealthChecker) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		status := h.Status()
+		code := http.StatusOK
+		if !status.Healthy {
+			code = http.StatusServiceUnavailable
+		}
+		writeHealthStatus(w, code, status)
+	})
+}
+
+// LivenessHandler returns an http.Handler reporting whether the health checker is still running.
+// Since an unreachable Loops API is no reason to restart a service, it only responds with 503 Service Unavailable
+// if no health check has completed for three check intervals, and with 200 OK otherwise.
+func (h *HealthChecker) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		status := h.Status()
+		code := http.StatusOK
+		if !status.CheckedAt.IsZero() && time.Since(status.CheckedAt) > 3*h.interval {
+			code = http.StatusServiceUnavailable
+		}
+		writeHealthStatus(w, code, status)
+	})
+}
+
+func writeHealthStatus(w http.ResponseWriter, code int, status HealthStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,69 @@
+package loops
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthCheckerHealthy(t *testing.T) {
+	checker := NewHealthChecker(newReplayTestClient(t, "test-api-key.replay.json"))
+
+	// not ready until the first check completed
+	rec := httptest.NewRecorder()
+	checker.ReadinessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	require.NoError(t, checker.Check(context.Background()))
+	status := checker.Status()
+	assert.True(t, status.Healthy)
+	assert.Equal(t, "Tilebox Staging", status.TeamName)
+	require.NoError(t, status.Err)
+
+	rec = httptest.NewRecorder()
+	checker.ReadinessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"teamName":"Tilebox Staging"`)
+
+	rec = httptest.NewRecorder()
+	checker.LivenessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestHealthCheckerInvalidAPIKey(t *testing.T) {
+	checker := NewHealthChecker(newReplayTestClient(t, "test-api-key-invalid.replay.json"))
+
+	require.Error(t, checker.Check(context.Background()))
+	status := checker.Status()
+	assert.False(t, status.Healthy)
+	require.Error(t, status.Err)
+
+	rec := httptest.NewRecorder()
+	checker.ReadinessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Invalid API key")
+
+	// an invalid key is no reason to restart the service
+	rec = httptest.NewRecorder()
+	checker.LivenessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestHealthCheckerRun(t *testing.T) {
+	httpClient := httpClientFunc(func(*http.Request) (*http.Response, error) {
+		return jsonResponse(http.StatusOK, `{"success":true,"teamName":"Tilebox Staging"}`), nil
+	})
+	client, err := NewClient(WithHTTPClient(httpClient))
+	require.NoError(t, err)
+	checker := NewHealthChecker(client, WithCheckInterval(time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go checker.Run(ctx)
+	require.Eventually(t, func() bool { return checker.Status().Healthy }, time.Second, time.Millisecond)
+}
@@ -0,0 +1,158 @@
+package loops
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// defaultHedgeMinSamples is the number of observed latencies needed before a percentile based hedge delay is used.
+const defaultHedgeMinSamples = 20
+
+// hedgeLatencyWindow is the number of most recent latencies kept per path to compute percentiles from.
+const hedgeLatencyWindow = 128
+
+// HedgePolicy configures hedged requests for idempotent read (GET) operations, such as FindContact.
+// If a request hasn't returned after the hedge delay, a second identical request is sent, and whichever returns first
+// is used while the other one is cancelled.
+// A hedge request is only sent if the client's concurrency limit allows it without waiting, so hedging never
+// exceeds the configured request budget.
+type HedgePolicy struct {
+	// Delay after which a hedge request is sent. Used as long as not enough latencies have been observed for
+	// Percentile, or if Percentile is not set.
+	Delay time.Duration
+	// Percentile of observed latencies for the same path (e.g. 0.95) after which a hedge request is sent.
+	// Must be between 0 and 1, 0 disables percentile based delays.
+	Percentile float64
+	// MinSamples is the number of observed latencies required before Percentile is used (default 20).
+	MinSamples int
+}
+
+// WithHedging enables hedged requests for idempotent read operations, using the given policy.
+func WithHedging(policy HedgePolicy) ClientOption {
+	return func(c *clientConfig) {
+		c.hedgePolicy = &policy
+	}
+}
+
+// hedger sends hedged requests, and tracks observed latencies per path to derive percentile based hedge delays.
+type hedger struct {
+	policy HedgePolicy
+
+	mu        sync.Mutex
+	latencies map[string][]time.Duration
+}
+
+func newHedger(policy *HedgePolicy) (*hedger, error) {
+	if policy == nil {
+		return nil, nil //nolint:nilnil // hedging is disabled, which is not an error
+	}
+	if policy.Delay < 0 {
+		return nil, errors.New("invalid hedge policy: delay must not be negative")
+	}
+	if policy.Percentile < 0 || policy.Percentile >= 1 {
+		return nil, errors.New("invalid hedge policy: percentile must be between 0 and 1")
+	}
+	if policy.Delay == 0 && policy.Percentile == 0 {
+		return nil, errors.New("invalid hedge policy: either delay or percentile must be set")
+	}
+	p := *policy
+	if p.MinSamples <= 0 {
+		p.MinSamples = defaultHedgeMinSamples
+	}
+	return &hedger{policy: p, latencies: make(map[string][]time.Duration)}, nil
+}
+
+type attemptResult struct {
+	resp *http.Response
+	body []byte
+	err  error
+}
+
+// roundTrip sends the request, and a hedge request if the first one doesn't return within the hedge delay.
+func (h *hedger) roundTrip(c *Client, req *http.Request) (*http.Response, []byte, error) {
+	release, err := c.limits.acquire(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	results := make(chan attemptResult, 2)
+	var cancels []context.CancelFunc
+	defer func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}()
+
+	attempt := func(release func()) {
+		ctx, cancel := context.WithCancel(req.Context())
+		cancels = append(cancels, cancel)
+		go func() {
+			defer release()
+			start := time.Now()
+			resp, body, err := c.send(req.Clone(ctx))
+			if err == nil {
+				h.observe(req.URL.Path, time.Since(start))
+			}
+			results <- attemptResult{resp: resp, body: body, err: err}
+		}()
+	}
+
+	attempt(release)
+	pending := 1
+
+	var hedgeTimeout <-chan time.Time
+	if delay, ok := h.delay(req.URL.Path); ok {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		hedgeTimeout = timer.C
+	}
+
+	for {
+		select {
+		case <-hedgeTimeout:
+			hedgeTimeout = nil
+			if release, ok := c.limits.tryAcquire(req); ok {
+				attempt(release)
+				pending++
+			}
+		case result := <-results:
+			pending--
+			if result.err == nil || pending == 0 {
+				return result.resp, result.body, result.err
+			}
+		}
+	}
+}
+
+// delay returns the hedge delay for requests to the given path, or false if no hedge request should be sent.
+func (h *hedger) delay(path string) (time.Duration, bool) {
+	if h.policy.Percentile == 0 {
+		return h.policy.Delay, true
+	}
+
+	h.mu.Lock()
+	latencies := slices.Clone(h.latencies[path])
+	h.mu.Unlock()
+
+	if len(latencies) < h.policy.MinSamples {
+		// without a fallback delay, don't hedge until there are enough samples
+		return h.policy.Delay, h.policy.Delay > 0
+	}
+	slices.Sort(latencies)
+	return latencies[int(h.policy.Percentile*float64(len(latencies)-1))], true
+}
+
+// observe records the latency of a successful request to the given path.
+func (h *hedger) observe(path string, latency time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	latencies := h.latencies[path]
+	if len(latencies) >= hedgeLatencyWindow {
+		latencies = latencies[1:]
+	}
+	h.latencies[path] = append(latencies, latency)
+}
@@ -0,0 +1,86 @@
+package loops
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowFirstHTTPClient blocks the first request until it is cancelled, and answers all following ones immediately.
+type slowFirstHTTPClient struct {
+	requests  atomic.Int64
+	cancelled atomic.Int64
+}
+
+func (s *slowFirstHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	if s.requests.Add(1) == 1 {
+		<-req.Context().Done()
+		s.cancelled.Add(1)
+		return nil, req.Context().Err()
+	}
+	return jsonResponse(http.StatusOK, `[{"id":"123","email":"test@example.com","subscribed":true}]`), nil
+}
+
+func TestHedgedRequest(t *testing.T) {
+	httpClient := &slowFirstHTTPClient{}
+	client, err := NewClient(WithHTTPClient(httpClient), WithHedging(HedgePolicy{Delay: 10 * time.Millisecond}))
+	require.NoError(t, err)
+
+	contact, err := client.FindContact(context.Background(), &ContactIdentifier{Email: String("test@example.com")})
+	require.NoError(t, err)
+	assert.Equal(t, "123", contact.ID)
+	assert.Equal(t, int64(2), httpClient.requests.Load())
+	require.Eventually(t, func() bool { return httpClient.cancelled.Load() == 1 }, time.Second, time.Millisecond)
+}
+
+func TestHedgedRequestRespectsConcurrencyLimit(t *testing.T) {
+	httpClient := &slowFirstHTTPClient{}
+	client, err := NewClient(WithHTTPClient(httpClient), WithMaxConcurrency(1),
+		WithHedging(HedgePolicy{Delay: time.Millisecond}))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = client.FindContact(ctx, &ContactIdentifier{Email: String("test@example.com")})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, int64(1), httpClient.requests.Load())
+}
+
+func TestHedgingNotUsedForWrites(t *testing.T) {
+	httpClient := &slowFirstHTTPClient{}
+	client, err := NewClient(WithHTTPClient(httpClient), WithHedging(HedgePolicy{Delay: time.Millisecond}))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = client.SendEvent(ctx, &Event{Email: String("test@example.com"), EventName: "signup"})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, int64(1), httpClient.requests.Load())
+}
+
+func TestHedgeDelayPercentile(t *testing.T) {
+	h, err := newHedger(&HedgePolicy{Percentile: 0.9, MinSamples: 10})
+	require.NoError(t, err)
+
+	_, ok := h.delay("/contacts/find")
+	assert.False(t, ok, "no hedging without enough samples and no fallback delay")
+
+	for i := range 10 {
+		h.observe("/contacts/find", time.Duration(i+1)*time.Millisecond)
+	}
+	delay, ok := h.delay("/contacts/find")
+	require.True(t, ok)
+	assert.Equal(t, 9*time.Millisecond, delay)
+}
+
+func TestHedgePolicyInvalid(t *testing.T) {
+	_, err := NewClient(WithHedging(HedgePolicy{}))
+	require.Error(t, err)
+	_, err = NewClient(WithHedging(HedgePolicy{Percentile: 1.5}))
+	require.Error(t, err)
+}
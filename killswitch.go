@@ -0,0 +1,238 @@
+package loops
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrOperationDisabled is returned (wrapped in an OperationDisabledError) for calls of operations that are disabled
+// by a kill switch.
+var ErrOperationDisabled = errors.New("operation disabled")
+
+// OperationDisabledError is returned for calls of operations that are disabled by a kill switch.
+type OperationDisabledError struct {
+	Operation Operation
+}
+
+func (e *OperationDisabledError) Error() string {
+	return fmt.Sprintf("operation %s is disabled by a kill switch", e.Operation)
+}
+
+func (e *OperationDisabledError) Is(target error) bool {
+	return target == ErrOperationDisabled
+}
+
+// divertibleOperations are the operations whose calls can be diverted to a sink while they are disabled.
+var divertibleOperations = []Operation{OperationSendEvent, OperationSendTransactionalEmail}
+
+// KillSwitches is a registry of operations that are disabled at runtime, e.g. during an incident.
+// It is safe for concurrent use, and can be shared between clients.
+type KillSwitches struct {
+	mu       sync.RWMutex
+	disabled map[Operation]bool
+}
+
+// NewKillSwitches creates a new kill switch registry, with all operations enabled.
+func NewKillSwitches() *KillSwitches {
+	return &KillSwitches{disabled: make(map[Operation]bool)}
+}
+
+// Disable disables the given operations, so calls to them fail with ErrOperationDisabled.
+func (k *KillSwitches) Disable(ops ...Operation) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for _, op := range ops {
+		k.disabled[op] = true
+	}
+}
+
+// Enable enables the given operations again.
+func (k *KillSwitches) Enable(ops ...Operation) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for _, op := range ops {
+		delete(k.disabled, op)
+	}
+}
+
+// IsDisabled returns whether the given operation is disabled.
+func (k *KillSwitches) IsDisabled(op Operation) bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.disabled[op]
+}
+
+// Disabled returns all disabled operations, in sorted order.
+func (k *KillSwitches) Disabled() []Operation {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	ops := make([]Operation, 0, len(k.disabled))
+	for op := range k.disabled {
+		ops = append(ops, op)
+	}
+	slices.Sort(ops)
+	return ops
+}
+
+// killSwitchRequest is the request body accepted by KillSwitches.ServeHTTP to toggle a kill switch.
+type killSwitchRequest struct {
+	Operation Operation `json:"operation"`
+	Disabled  bool      `json:"disabled"`
+}
+
+// ServeHTTP exposes the kill switches over HTTP. GET lists the disabled operations, and POST toggles a kill switch
+// with a JSON body such as {"operation": "sendTransactionalEmail", "disabled": true}.
+// It doesn't perform any authentication, so it should only be exposed on an internal port.
+func (k *KillSwitches) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		toggle := killSwitchRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&toggle); err != nil {
+			http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		if !slices.Contains(allOperations, toggle.Operation) {
+			http.Error(w, fmt.Sprintf("unknown operation: %q", toggle.Operation), http.StatusBadRequest)
+			return
+		}
+		if toggle.Disabled {
+			k.Disable(toggle.Operation)
+		} else {
+			k.Enable(toggle.Operation)
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string][]Operation{"disabled": k.Disabled()})
+}
+
+// WithKillSwitches makes the client consult the given kill switches before each operation, failing calls of
+// disabled operations with ErrOperationDisabled.
+func WithKillSwitches(killSwitches *KillSwitches) ClientOption {
+	return func(c *clientConfig) {
+		c.killSwitches = killSwitches
+	}
+}
+
+// DivertedCall is a call of a disabled operation that was diverted to a sink, so it can be replayed later using
+// Client.ReplayDiverted.
+type DivertedCall struct {
+	Operation  Operation       `json:"operation"`
+	Payload    json.RawMessage `json:"payload"`
+	DivertedAt time.Time       `json:"divertedAt"`
+}
+
+// DivertSink stores calls of disabled operations for later replay.
+type DivertSink interface {
+	Divert(ctx context.Context, call *DivertedCall) error
+}
+
+// WithDivertSink diverts calls of disabled send operations (SendEvent and SendTransactionalEmail) to the given sink
+// instead of failing them. Diverted calls return without error. If the sink implements Flusher, it is flushed when
+// the client is closed.
+func WithDivertSink(sink DivertSink) ClientOption {
+	return func(c *clientConfig) {
+		c.divertSink = sink
+	}
+}
+
+// ReplayDiverted sends a previously diverted call.
+func (c *Client) ReplayDiverted(ctx context.Context, call *DivertedCall) error {
+	switch call.Operation {
+	case OperationSendEvent:
+		event := &Event{}
+		if err := c.codec.Unmarshal(call.Payload, event); err != nil {
+			return fmt.Errorf("invalid diverted event: %w", err)
+		}
+		return c.SendEvent(ctx, event)
+	case OperationSendTransactionalEmail:
+		transactional := &TransactionalEmail{}
+		if err := c.codec.Unmarshal(call.Payload, transactional); err != nil {
+			return fmt.Errorf("invalid diverted transactional email: %w", err)
+		}
+		return c.SendTransactionalEmail(ctx, transactional)
+	default:
+		return fmt.Errorf("operation %s cannot be replayed", call.Operation)
+	}
+}
+
+// checkKillSwitch returns an OperationDisabledError if op is disabled. If a divert sink is configured and op can be
+// diverted, the call is stored in the sink instead, and false is returned without an error.
+func (c *Client) checkKillSwitch(ctx context.Context, op Operation, payload any) (bool, error) {
+	if c.killSwitches == nil || !c.killSwitches.IsDisabled(op) {
+		return true, nil
+	}
+	if c.divertSink == nil || !slices.Contains(divertibleOperations, op) {
+		return false, &OperationDisabledError{Operation: op}
+	}
+
+	body, err := c.codec.Marshal(payload)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal diverted call: %w", err)
+	}
+	call := &DivertedCall{Operation: op, Payload: body, DivertedAt: time.Now()}
+	if err := c.divertSink.Divert(ctx, call); err != nil {
+		return false, fmt.Errorf("failed to divert call of disabled operation %s: %w", op, err)
+	}
+	return false, nil
+}
+
+// WriterSink is a DivertSink writing diverted calls as JSON lines to an io.Writer.
+type WriterSink struct {
+	mu sync.Mutex
+	w  *bufio.Writer
+}
+
+// NewWriterSink creates a new sink writing diverted calls as JSON lines to w.
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: bufio.NewWriter(w)}
+}
+
+func (s *WriterSink) Divert(_ context.Context, call *DivertedCall) error {
+	line, err := json.Marshal(call)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.w.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *WriterSink) Flush(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Flush()
+}
+
+// ReadDivertedCalls reads diverted calls written by a WriterSink.
+func ReadDivertedCalls(r io.Reader) ([]*DivertedCall, error) {
+	var calls []*DivertedCall
+	decoder := json.NewDecoder(r)
+	for {
+		call := &DivertedCall{}
+		err := decoder.Decode(call)
+		if errors.Is(err, io.EOF) {
+			return calls, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid diverted call: %w", err)
+		}
+		calls = append(calls, call)
+	}
+}
@@ -0,0 +1,111 @@
+package loops
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingHTTPClient answers every request successfully, recording the request bodies it received.
+type recordingHTTPClient struct {
+	bodies []string
+}
+
+func (r *recordingHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	body := ""
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		body = string(b)
+	}
+	r.bodies = append(r.bodies, body)
+	return jsonResponse(http.StatusOK, `{"success":true,"id":"123","message":""}`), nil
+}
+
+func TestKillSwitchDisablesOperation(t *testing.T) {
+	httpClient := &recordingHTTPClient{}
+	killSwitches := NewKillSwitches()
+	client, err := NewClient(WithHTTPClient(httpClient), WithKillSwitches(killSwitches))
+	require.NoError(t, err)
+
+	killSwitches.Disable(OperationDeleteContact)
+	err = client.DeleteContact(context.Background(), &ContactIdentifier{Email: String("test@example.com")})
+	require.ErrorIs(t, err, ErrOperationDisabled)
+	var disabledErr *OperationDisabledError
+	require.ErrorAs(t, err, &disabledErr)
+	assert.Equal(t, OperationDeleteContact, disabledErr.Operation)
+	assert.Empty(t, httpClient.bodies)
+
+	killSwitches.Enable(OperationDeleteContact)
+	err = client.DeleteContact(context.Background(), &ContactIdentifier{Email: String("test@example.com")})
+	require.NoError(t, err)
+	assert.Len(t, httpClient.bodies, 1)
+}
+
+func TestKillSwitchDivertsAndReplaysSends(t *testing.T) {
+	httpClient := &recordingHTTPClient{}
+	killSwitches := NewKillSwitches()
+	buf := &bytes.Buffer{}
+	client, err := NewClient(WithHTTPClient(httpClient), WithKillSwitches(killSwitches), WithDivertSink(NewWriterSink(buf)))
+	require.NoError(t, err)
+
+	killSwitches.Disable(OperationSendTransactionalEmail, OperationCreateContact)
+	err = client.SendTransactionalEmail(context.Background(), &TransactionalEmail{
+		TransactionalID: "cm3n2vjux00cgeyeflew9ly2w",
+		Email:           "test@example.com",
+	})
+	require.NoError(t, err)
+
+	// operations other than sends are never diverted
+	_, err = client.CreateContact(context.Background(), &Contact{Email: "test@example.com"})
+	require.ErrorIs(t, err, ErrOperationDisabled)
+	assert.Empty(t, httpClient.bodies)
+
+	// the sink is flushed on close
+	require.NoError(t, client.Close(context.Background()))
+	calls, err := ReadDivertedCalls(buf)
+	require.NoError(t, err)
+	require.Len(t, calls, 1)
+	assert.Equal(t, OperationSendTransactionalEmail, calls[0].Operation)
+
+	replayClient, err := NewClient(WithHTTPClient(httpClient))
+	require.NoError(t, err)
+	require.NoError(t, replayClient.ReplayDiverted(context.Background(), calls[0]))
+	require.Len(t, httpClient.bodies, 1)
+	assert.JSONEq(t, `{"transactionalId":"cm3n2vjux00cgeyeflew9ly2w","email":"test@example.com"}`, httpClient.bodies[0])
+}
+
+func TestKillSwitchesHandler(t *testing.T) {
+	killSwitches := NewKillSwitches()
+
+	rec := httptest.NewRecorder()
+	body := strings.NewReader(`{"operation":"sendTransactionalEmail","disabled":true}`)
+	killSwitches.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/killswitches", body))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"disabled":["sendTransactionalEmail"]}`, rec.Body.String())
+	assert.True(t, killSwitches.IsDisabled(OperationSendTransactionalEmail))
+
+	rec = httptest.NewRecorder()
+	body = strings.NewReader(`{"operation":"sendTransactionalEmail","disabled":false}`)
+	killSwitches.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/killswitches", body))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.False(t, killSwitches.IsDisabled(OperationSendTransactionalEmail))
+
+	rec = httptest.NewRecorder()
+	body = strings.NewReader(`{"operation":"launchRocket","disabled":true}`)
+	killSwitches.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/killswitches", body))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	killSwitches.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/killswitches", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
@@ -0,0 +1,135 @@
+package loops
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// ErrClientClosed is returned for calls made after the client has been closed.
+var ErrClientClosed = errors.New("client closed")
+
+// Flusher is implemented by sinks attached to the client (e.g. loggers or audit writers) that buffer data which
+// needs to be written out when the client is closed.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// WithFlushers attaches sinks to the client, which are flushed when the client is closed.
+func WithFlushers(flushers ...Flusher) ClientOption {
+	return func(c *clientConfig) {
+		c.flushers = append(c.flushers, flushers...)
+	}
+}
+
+// Close shuts down the client. It stops accepting new calls, which fail with ErrClientClosed from then on,
+// and waits for in-flight requests to finish. If ctx is done before that, the remaining requests are cancelled.
+// Afterwards, all sinks attached using WithFlushers are flushed.
+// Calling Close more than once returns ErrClientClosed.
+func (c *Client) Close(ctx context.Context) error {
+	if !c.lifecycle.close() {
+		return ErrClientClosed
+	}
+
+	var errs []error
+	if err := c.lifecycle.drain(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	for _, flusher := range c.flushers {
+		if err := flusher.Flush(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// lifecycle tracks the requests a client has in flight, so they can be drained or cancelled on close.
+type lifecycle struct {
+	mu       sync.Mutex
+	closed   bool
+	inFlight sync.WaitGroup
+	nextID   uint64
+	cancels  map[uint64]context.CancelFunc
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{cancels: make(map[uint64]context.CancelFunc)}
+}
+
+// begin registers a request as in flight, returning a copy of it that is cancelled on a forced shutdown,
+// and a function to call once the request is done.
+func (l *lifecycle) begin(req *http.Request) (*http.Request, func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil, nil, ErrClientClosed
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	id := l.nextID
+	l.nextID++
+	l.cancels[id] = cancel
+	l.inFlight.Add(1)
+
+	done := func() {
+		l.mu.Lock()
+		delete(l.cancels, id)
+		l.mu.Unlock()
+		cancel()
+		l.inFlight.Done()
+	}
+	return req.WithContext(ctx), done, nil
+}
+
+// checkOpen returns ErrClientClosed if the lifecycle is closed. Calls check this before anything else, so calls made
+// after close are neither diverted to a sink nor counted against limits and quotas.
+func (l *lifecycle) checkOpen() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return ErrClientClosed
+	}
+	return nil
+}
+
+// close marks the lifecycle as closed, so no new requests are accepted. It returns false if it was already closed.
+func (l *lifecycle) close() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return false
+	}
+	l.closed = true
+	return true
+}
+
+// state returns whether the lifecycle is closed, and the number of requests in flight.
+func (l *lifecycle) state() (bool, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.closed, len(l.cancels)
+}
+
+// drain waits for all in-flight requests to finish. If ctx is done first, the remaining requests are cancelled.
+func (l *lifecycle) drain(ctx context.Context) error {
+	drained := make(chan struct{})
+	go func() {
+		l.inFlight.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	for _, cancel := range l.cancels {
+		cancel()
+	}
+	l.mu.Unlock()
+	<-drained
+	return ctx.Err()
+}
@@ -0,0 +1,105 @@
+package loops
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type flushRecorder struct {
+	flushed int
+}
+
+func (f *flushRecorder) Flush(context.Context) error {
+	f.flushed++
+	return nil
+}
+
+func TestCloseWaitsForInFlightRequests(t *testing.T) {
+	httpClient := &blockingHTTPClient{unblock: make(chan struct{})}
+	sink := &flushRecorder{}
+	client, err := NewClient(WithHTTPClient(httpClient), WithFlushers(sink))
+	require.NoError(t, err)
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, err := client.UpdateContact(context.Background(), &Contact{Email: "test@example.com"})
+		assert.NoError(t, err)
+	}()
+	require.Eventually(t, func() bool { return httpClient.inFlight.Load() == 1 }, time.Second, time.Millisecond)
+
+	closed := make(chan error)
+	go func() { closed <- client.Close(context.Background()) }()
+
+	// new calls are rejected while the client is draining
+	require.Eventually(t, func() bool {
+		client.lifecycle.mu.Lock()
+		defer client.lifecycle.mu.Unlock()
+		return client.lifecycle.closed
+	}, time.Second, time.Millisecond)
+	_, err = client.GetMailingLists(context.Background())
+	require.ErrorIs(t, err, ErrClientClosed)
+
+	close(httpClient.unblock)
+	require.NoError(t, <-closed)
+	wg.Wait()
+	assert.Equal(t, 1, sink.flushed)
+
+	require.ErrorIs(t, client.Close(context.Background()), ErrClientClosed)
+}
+
+func TestCloseCancelsRequestsAfterDeadline(t *testing.T) {
+	httpClient := &blockingHTTPClient{unblock: make(chan struct{})}
+	defer close(httpClient.unblock)
+	sink := &flushRecorder{}
+	client, err := NewClient(WithHTTPClient(httpClient), WithFlushers(sink))
+	require.NoError(t, err)
+
+	requestErr := make(chan error)
+	go func() {
+		_, err := client.UpdateContact(context.Background(), &Contact{Email: "test@example.com"})
+		requestErr <- err
+	}()
+	require.Eventually(t, func() bool { return httpClient.inFlight.Load() == 1 }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, client.Close(ctx), context.DeadlineExceeded)
+	require.ErrorIs(t, <-requestErr, context.Canceled)
+	assert.Equal(t, 1, sink.flushed)
+}
+
+func TestClosedClientRejectsCallsBeforeDiverting(t *testing.T) {
+	httpClient := &recordingHTTPClient{}
+	killSwitches := NewKillSwitches()
+	buf := &bytes.Buffer{}
+	sink := NewWriterSink(buf)
+	store := NewMemoryCounterStore()
+	client, err := NewClient(WithHTTPClient(httpClient), WithKillSwitches(killSwitches), WithDivertSink(sink),
+		WithQuotas(store, Quota{Scope: QuotaScopeEventName, Limit: 1, Window: time.Hour}),
+	)
+	require.NoError(t, err)
+	require.NoError(t, client.Close(context.Background()))
+
+	ctx := context.Background()
+	killSwitches.Disable(OperationSendEvent)
+	err = client.SendEvent(ctx, &Event{Email: String("test@example.com"), EventName: "signup"})
+	require.ErrorIs(t, err, ErrClientClosed)
+	killSwitches.Enable(OperationSendEvent)
+	err = client.SendEvent(ctx, &Event{Email: String("test@example.com"), EventName: "signup"})
+	require.ErrorIs(t, err, ErrClientClosed)
+	require.ErrorIs(t, client.Do(ctx, http.MethodGet, "/contacts/suppression", nil, nil), ErrClientClosed)
+
+	require.NoError(t, sink.Flush(ctx))
+	assert.Empty(t, buf.String())
+	assert.Empty(t, httpClient.bodies)
+	assert.Empty(t, store.counters)
+}
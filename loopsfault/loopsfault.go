@@ -0,0 +1,257 @@
+// Package loopsfault provides an HTTP client injecting faults into requests to the Loops API, to test how services
+// behave when Loops is slow or failing, using the real loops client:
+//
+//	injector := loopsfault.New(http.DefaultClient, loopsfault.WithRules(
+//		loopsfault.Rule{Path: "/transactional", Probability: 0.1, Fault: loopsfault.ServerError(http.StatusBadGateway)},
+//		loopsfault.Rule{Path: "/events/send", Sequence: []loopsfault.Fault{loopsfault.RateLimited(time.Second), loopsfault.None()}},
+//	))
+//	client, err := loops.NewClient(loops.WithAPIKey(apiKey), loops.WithHTTPClient(injector))
+package loopsfault
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tilebox/loops-go"
+)
+
+// ErrInjected is wrapped by the errors of injected connection errors and truncated bodies.
+var ErrInjected = errors.New("injected fault")
+
+// Fault describes how a request is disturbed. The zero value (see None) passes the request through unchanged.
+type Fault struct {
+	// latency delays the request before it is sent or failed.
+	latency time.Duration
+	// err fails the request with an error, without sending it.
+	err error
+	// respond answers the request with a synthetic response, without sending it.
+	respond func(req *http.Request) *http.Response
+	// mangle sends the request, and modifies the real response.
+	mangle func(resp *http.Response) (*http.Response, error)
+}
+
+// None passes the request through unchanged, e.g. to let requests succeed in between scripted faults.
+func None() Fault {
+	return Fault{}
+}
+
+// Latency delays the request by the given duration before sending it, unless its context is done first.
+func Latency(d time.Duration) Fault {
+	return Fault{latency: d}
+}
+
+// ConnectionError fails the request with an error wrapping ErrInjected, as if the connection was reset.
+func ConnectionError() Fault {
+	return Fault{err: fmt.Errorf("%w: connection reset by peer", ErrInjected)}
+}
+
+// RateLimited answers the request with a 429 response, including the rate limit headers sent by Loops and
+// a Retry-After header.
+func RateLimited(retryAfter time.Duration) Fault {
+	return Fault{respond: func(req *http.Request) *http.Response {
+		resp := jsonResponse(req, http.StatusTooManyRequests, `{"success":false,"message":"Rate limit exceeded."}`)
+		resp.Header.Set("X-RateLimit-Limit", "10")
+		resp.Header.Set("X-RateLimit-Remaining", "0")
+		resp.Header.Set("Retry-After", strconv.Itoa(int((retryAfter+time.Second-1)/time.Second)))
+		return resp
+	}}
+}
+
+// ServerError answers the request with the given 5xx status code.
+func ServerError(statusCode int) Fault {
+	return Fault{respond: func(req *http.Request) *http.Response {
+		return jsonResponse(req, statusCode, fmt.Sprintf(`{"success":false,"message":%q}`, http.StatusText(statusCode)))
+	}}
+}
+
+// TruncatedBody sends the request, but cuts off the response body halfway, as if the connection dropped while
+// reading it.
+func TruncatedBody() Fault {
+	return Fault{mangle: func(resp *http.Response) (*http.Response, error) {
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(io.MultiReader(
+			bytes.NewReader(body[:len(body)/2]),
+			errReader{fmt.Errorf("%w: %w", ErrInjected, io.ErrUnexpectedEOF)},
+		))
+		resp.ContentLength = -1
+		return resp, nil
+	}}
+}
+
+// MalformedJSON sends the request, but replaces the response body with malformed JSON.
+func MalformedJSON() Fault {
+	return Fault{mangle: func(resp *http.Response) (*http.Response, error) {
+		_ = resp.Body.Close()
+		resp.Body = io.NopCloser(strings.NewReader(`{"success":tru`))
+		resp.ContentLength = -1
+		return resp, nil
+	}}
+}
+
+// WithLatency returns a copy of the fault that additionally delays the request by the given duration, e.g. for
+// slow server errors.
+func (f Fault) WithLatency(d time.Duration) Fault {
+	f.latency = d
+	return f
+}
+
+func (f Fault) isNone() bool {
+	return f.latency == 0 && f.err == nil && f.respond == nil && f.mangle == nil
+}
+
+// Rule injects faults into requests matching its path and method.
+type Rule struct {
+	// The path the rule applies to, relative to the API URL (e.g. "/transactional"). Empty matches all paths.
+	Path string
+	// The HTTP method the rule applies to. Empty matches all methods.
+	Method string
+	// Sequence is a scripted sequence of faults, applied to consecutive matching requests. Once it is exhausted,
+	// Probability and Fault apply.
+	Sequence []Fault
+	// The probability (between 0 and 1) of injecting Fault into a matching request.
+	Probability float64
+	// The fault injected with the given probability.
+	Fault Fault
+}
+
+func (r *Rule) matches(req *http.Request) bool {
+	return (r.Method == "" || r.Method == req.Method) && (r.Path == "" || strings.HasSuffix(req.URL.Path, r.Path))
+}
+
+// Option configures an Injector.
+type Option func(*Injector)
+
+// WithRules adds rules to the injector. For each request, the first matching rule decides on the fault.
+func WithRules(rules ...Rule) Option {
+	return func(i *Injector) {
+		i.rules = append(i.rules, rules...)
+	}
+}
+
+// WithSeed seeds the random number generator deciding on probabilistic faults, for reproducible test runs.
+func WithSeed(seed uint64) Option {
+	return func(i *Injector) {
+		i.rand = rand.New(rand.NewPCG(seed, seed)) //nolint:gosec // no need for cryptographic randomness
+	}
+}
+
+// Injector is a loops.HTTPClient injecting faults into requests before passing them on to another HTTP client.
+// It is safe for concurrent use.
+type Injector struct {
+	next loops.HTTPClient
+
+	mu       sync.Mutex
+	rules    []Rule
+	rand     *rand.Rand
+	injected int
+}
+
+var _ loops.HTTPClient = (*Injector)(nil)
+
+// New creates an injector passing requests on to next, e.g. http.DefaultClient.
+func New(next loops.HTTPClient, opts ...Option) *Injector {
+	i := &Injector{next: next, rand: rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))} //nolint:gosec // no need for cryptographic randomness
+	for _, o := range opts {
+		o(i)
+	}
+	return i
+}
+
+// SetRules replaces the rules of the injector, restarting their sequences, e.g. to change faults mid test.
+func (i *Injector) SetRules(rules ...Rule) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.rules = slices.Clone(rules)
+}
+
+// Injected returns the number of requests faults were injected into so far.
+func (i *Injector) Injected() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.injected
+}
+
+func (i *Injector) Do(req *http.Request) (*http.Response, error) {
+	fault := i.fault(req)
+
+	if fault.latency > 0 {
+		timer := time.NewTimer(fault.latency)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+	if fault.err != nil {
+		return nil, fault.err
+	}
+	if fault.respond != nil {
+		return fault.respond(req), nil
+	}
+
+	resp, err := i.next.Do(req)
+	if err != nil || fault.mangle == nil {
+		return resp, err
+	}
+	return fault.mangle(resp)
+}
+
+// fault decides on the fault to inject into the request, advancing the sequence of the first matching rule.
+func (i *Injector) fault(req *http.Request) Fault {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for r := range i.rules {
+		rule := &i.rules[r]
+		if !rule.matches(req) {
+			continue
+		}
+		var fault Fault
+		if len(rule.Sequence) > 0 {
+			fault, rule.Sequence = rule.Sequence[0], rule.Sequence[1:]
+		} else if rule.Probability > 0 && i.rand.Float64() < rule.Probability {
+			fault = rule.Fault
+		}
+		if !fault.isNone() {
+			i.injected++
+		}
+		return fault
+	}
+	return Fault{}
+}
+
+func jsonResponse(req *http.Request, statusCode int, body string) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
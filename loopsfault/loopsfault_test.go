@@ -0,0 +1,125 @@
+package loopsfault
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tilebox/loops-go"
+)
+
+// okHTTPClient answers every request successfully, counting the requests it received.
+type okHTTPClient struct {
+	requests int
+}
+
+func (o *okHTTPClient) Do(*http.Request) (*http.Response, error) {
+	o.requests++
+	body := `{"success":true,"teamName":"Tilebox"}`
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}, nil
+}
+
+func newTestClient(t *testing.T, next loops.HTTPClient, opts ...Option) (*loops.Client, *Injector) {
+	t.Helper()
+	injector := New(next, opts...)
+	client, err := loops.NewClient(loops.WithHTTPClient(injector))
+	require.NoError(t, err)
+	return client, injector
+}
+
+func TestSequence(t *testing.T) {
+	next := &okHTTPClient{}
+	client, injector := newTestClient(t, next, WithRules(Rule{
+		Path:     "/api-key",
+		Sequence: []Fault{ConnectionError(), ServerError(http.StatusBadGateway), None()},
+	}))
+	ctx := context.Background()
+
+	_, err := client.TestAPIKey(ctx)
+	require.ErrorIs(t, err, ErrInjected)
+	_, err = client.TestAPIKey(ctx)
+	require.EqualError(t, err, "Bad Gateway")
+	info, err := client.TestAPIKey(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "Tilebox", info.TeamName)
+
+	assert.Equal(t, 1, next.requests)
+	assert.Equal(t, 2, injector.Injected())
+}
+
+func TestRateLimited(t *testing.T) {
+	injector := New(&okHTTPClient{}, WithRules(Rule{Sequence: []Fault{RateLimited(1500 * time.Millisecond)}}))
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "https://app.loops.so/api/v1/lists", nil)
+	require.NoError(t, err)
+
+	resp, err := injector.Do(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "2", resp.Header.Get("Retry-After"))
+	assert.Equal(t, "0", resp.Header.Get("X-RateLimit-Remaining"))
+}
+
+func TestMangledBodies(t *testing.T) {
+	next := &okHTTPClient{}
+	client, _ := newTestClient(t, next, WithRules(Rule{Sequence: []Fault{TruncatedBody(), MalformedJSON()}}))
+	ctx := context.Background()
+
+	_, err := client.TestAPIKey(ctx)
+	require.ErrorIs(t, err, ErrInjected)
+	_, err = client.TestAPIKey(ctx)
+	require.ErrorContains(t, err, "failed to unmarshal response body")
+	assert.Equal(t, 2, next.requests)
+}
+
+func TestLatencyRespectsContext(t *testing.T) {
+	client, _ := newTestClient(t, &okHTTPClient{}, WithRules(Rule{Sequence: []Fault{Latency(time.Hour)}}))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := client.TestAPIKey(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestProbabilityPerPath(t *testing.T) {
+	next := &okHTTPClient{}
+	client, injector := newTestClient(t, next, WithSeed(42), WithRules(
+		Rule{Path: "/api-key", Probability: 0.5, Fault: ServerError(http.StatusServiceUnavailable)},
+		Rule{Path: "/lists", Probability: 1, Fault: ConnectionError()},
+	))
+	ctx := context.Background()
+
+	failed := 0
+	for range 200 {
+		if _, err := client.TestAPIKey(ctx); err != nil {
+			failed++
+		}
+	}
+	assert.InDelta(t, 100, failed, 30)
+	assert.Equal(t, failed, injector.Injected())
+
+	_, err := client.GetMailingLists(ctx)
+	require.ErrorIs(t, err, ErrInjected)
+}
+
+func TestSetRules(t *testing.T) {
+	next := &okHTTPClient{}
+	client, injector := newTestClient(t, next, WithRules(Rule{Probability: 1, Fault: ConnectionError()}))
+
+	_, err := client.TestAPIKey(context.Background())
+	require.ErrorIs(t, err, ErrInjected)
+
+	injector.SetRules()
+	_, err = client.TestAPIKey(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, next.requests)
+}
@@ -3,7 +3,11 @@ package loops
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"maps"
+	"regexp"
+	"slices"
+	"time"
 )
 
 // String returns a pointer to the string value passed in.
@@ -42,112 +46,280 @@ type Contact struct {
 	MailingLists map[string]bool `json:"mailingLists,omitempty"`
 	// Double opt-in status.
 	OptInStatus *OptInStatus `json:"optInStatus,omitempty"`
-	// Custom properties for the contact.
-	Properties map[string]any `json:"-"` // there is no "customProperties", we need to inline add them to the json
+	// Custom properties for the contact. Properties that are null are decoded as nil values. time.Time values are sent
+	// as dates, and dates are decoded as time.Time if the client is configured WithPropertySchema.
+	Properties Properties `json:"-"` // there is no "customProperties", we need to inline add them to the json
+	// Issues found while decoding the contact from an API response, see DecodeWarning.
+	DecodeWarnings []DecodeWarning `json:"-"`
+}
+
+// contactFields are the names of the standard contact fields, in sorted order. They are reserved, so custom
+// properties can't use them.
+var contactFields = [...]string{
+	"email", "firstName", "id", "lastName", "mailingLists", "optInStatus", "source", "subscribed", "userGroup", "userId",
 }
 
 // MarshalJSON overrides the default json marshaller to add custom properties inline to the root object
 func (c *Contact) MarshalJSON() ([]byte, error) {
-	data := map[string]any{
-		"id":         c.ID,
-		"email":      c.Email,
-		"subscribed": c.Subscribed,
-	}
-	if c.FirstName != nil {
-		data["firstName"] = *c.FirstName
-	}
-	if c.LastName != nil {
-		data["lastName"] = *c.LastName
-	}
-	if c.Source != nil {
-		data["source"] = *c.Source
-	}
-	if c.UserGroup != nil {
-		data["userGroup"] = *c.UserGroup
-	}
-	if c.UserID != nil {
-		data["userId"] = *c.UserID
+	return marshalAppender(c)
+}
+
+// appendJSON appends the contact as JSON object with all keys, including custom properties, in sorted order.
+// A custom property with the same name as a standard field is rejected with a ReservedPropertyError.
+func (c *Contact) appendJSON(dst []byte) ([]byte, error) {
+	if err := checkReservedProperties(c.Properties); err != nil {
+		return nil, err
 	}
-	if c.MailingLists != nil {
-		data["mailingLists"] = c.MailingLists
+	return appendObjectWithProperties(dst, contactFields[:], c.appendField, c.Properties)
+}
+
+// appendField appends the standard contact field with the given name, if it is set.
+func (c *Contact) appendField(w *objectWriter, name string) error {
+	switch name {
+	case "id":
+		w.string(name, c.ID)
+	case "email":
+		w.string(name, c.Email)
+	case "subscribed":
+		w.bool(name, c.Subscribed)
+	case "firstName":
+		appendOptionalString(w, name, c.FirstName)
+	case "lastName":
+		appendOptionalString(w, name, c.LastName)
+	case "source":
+		appendOptionalString(w, name, c.Source)
+	case "userGroup":
+		appendOptionalString(w, name, c.UserGroup)
+	case "userId":
+		appendOptionalString(w, name, c.UserID)
+	case "mailingLists":
+		if c.MailingLists != nil {
+			w.key(name)
+			w.buf = appendJSONBoolMap(w.buf, c.MailingLists)
+		}
+	case "optInStatus":
+		if c.OptInStatus != nil {
+			w.string(name, string(*c.OptInStatus))
+		}
 	}
-	if c.OptInStatus != nil {
-		data["optInStatus"] = *c.OptInStatus
+	return nil
+}
+
+func appendOptionalString(w *objectWriter, name string, v *string) {
+	if v != nil {
+		w.string(name, *v)
 	}
-	maps.Copy(data, c.Properties)
-	return json.Marshal(data)
 }
 
 // UnmarshalJSON overrides the default json unmarshaller to add custom properties inline to the root object
 func (c *Contact) UnmarshalJSON(data []byte) error {
-	values := map[string]any{}
-	if err := json.Unmarshal(data, &values); err != nil {
-		return err
-	}
+	return c.decode(data, decodeOptions{})
+}
 
-	if id, ok := values["id"].(string); ok {
-		c.ID = id
-		delete(values, "id")
-	} else {
-		return errors.New("missing or invalid 'id' field")
+// decode decodes a contact from JSON. Standard fields that are null are treated as absent. Issues that don't
+// prevent decoding, such as a standard field of an unexpected type, are recorded in DecodeWarnings. In lenient mode,
+// issues that would otherwise fail decoding, such as a missing email, are recorded as warnings as well.
+func (c *Contact) decode(data []byte, opts decodeOptions) error {
+	if !json.Valid(data) {
+		return errors.New("invalid contact JSON")
 	}
 
-	if email, ok := values["email"].(string); ok {
-		c.Email = email
-		delete(values, "email")
-	} else {
-		return errors.New("missing or invalid 'email' field")
-	}
+	var hasID, hasEmail, hasSubscribed bool
+	c.Properties = make(Properties)
+	c.DecodeWarnings = nil
+	err := eachObjectField(data, func(rawKey, value []byte) error {
+		key := string(decodeJSONKey(rawKey))
+		if isJSONNull(value) && slices.Contains(contactFields[:], key) {
+			return nil // a cleared standard field, which is the same as an absent one
+		}
+		switch key {
+		case "id":
+			c.ID, hasID = decodeJSONString(value)
+			if hasID {
+				return nil
+			}
+		case "email":
+			c.Email, hasEmail = decodeJSONString(value)
+			if hasEmail {
+				return nil
+			}
+		case "subscribed":
+			c.Subscribed, hasSubscribed = decodeJSONBool(value)
+			if hasSubscribed {
+				return nil
+			}
+		case "firstName":
+			if decodeOptionalString(value, &c.FirstName) {
+				return nil
+			}
+		case "lastName":
+			if decodeOptionalString(value, &c.LastName) {
+				return nil
+			}
+		case "source":
+			if decodeOptionalString(value, &c.Source) {
+				return nil
+			}
+		case "userGroup":
+			if decodeOptionalString(value, &c.UserGroup) {
+				return nil
+			}
+		case "userId":
+			if decodeOptionalString(value, &c.UserID) {
+				return nil
+			}
+		case "mailingLists":
+			if value[0] == '{' {
+				c.MailingLists = make(map[string]bool)
+				return eachObjectField(value, func(rawListID, rawSubscribed []byte) error {
+					listID := string(decodeJSONKey(rawListID))
+					subscribed, ok := decodeJSONBool(rawSubscribed)
+					if !ok {
+						if !opts.lenient {
+							return fmt.Errorf("invalid 'mailingLists' field: subscription status of %q is not a boolean", listID)
+						}
+						c.warn("mailingLists."+listID, "subscription status is not a boolean, list skipped")
+						return nil
+					}
+					c.MailingLists[listID] = subscribed
+					return nil
+				})
+			}
+		case "optInStatus":
+			if status, ok := decodeJSONString(value); ok {
+				optInStatus := OptInStatus(status)
+				c.OptInStatus = &optInStatus
+				return nil
+			}
+		}
 
-	if subscribed, ok := values["subscribed"].(bool); ok {
-		c.Subscribed = subscribed
-		delete(values, "subscribed")
-	} else {
-		return errors.New("missing or invalid 'subscribed' field")
-	}
+		if slices.Contains(contactFields[:], key) {
+			// a standard field of an unexpected type, which can't be kept as custom property of the same name
+			c.warn(key, "unexpected type, ignored")
+			return nil
+		}
 
-	if firstName, ok := values["firstName"].(string); ok {
-		c.FirstName = &firstName
-		delete(values, "firstName")
+		// not a standard field, so it's a custom property
+		property, err := decodeJSONValue(value, opts.exactNumbers)
+		if err != nil {
+			return fmt.Errorf("invalid %q field: %w", key, err)
+		}
+		if opts.dateProperties[key] && property != nil {
+			date, err := Properties{key: property}.Time(key)
+			switch {
+			case err == nil:
+				property = date
+			case !opts.lenient:
+				return fmt.Errorf("invalid %q field: %w", key, err)
+			default:
+				c.warn(key, "invalid date, decoded as is")
+			}
+		}
+		c.Properties[key] = property
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	if lastName, ok := values["lastName"].(string); ok {
-		c.LastName = &lastName
-		delete(values, "lastName")
+	for _, required := range []struct {
+		field string
+		ok    bool
+	}{{"id", hasID}, {"email", hasEmail}, {"subscribed", hasSubscribed}} {
+		if required.ok {
+			continue
+		}
+		if !opts.lenient {
+			return fmt.Errorf("missing or invalid '%s' field", required.field)
+		}
+		c.warn(required.field, "missing or invalid")
 	}
+	return nil
+}
 
-	if source, ok := values["source"].(string); ok {
-		c.Source = &source
-		delete(values, "source")
-	}
+// warn records a decode warning for the given field.
+func (c *Contact) warn(field, issue string) {
+	c.DecodeWarnings = append(c.DecodeWarnings, DecodeWarning{Field: field, Issue: issue})
+}
 
-	if userGroup, ok := values["userGroup"].(string); ok {
-		c.UserGroup = &userGroup
-		delete(values, "userGroup")
+// decodeOptionalString decodes a raw JSON string value into target, returning false if it is not a string.
+func decodeOptionalString(raw []byte, target **string) bool {
+	s, ok := decodeJSONString(raw)
+	if ok {
+		*target = &s
 	}
+	return ok
+}
 
-	if userID, ok := values["userId"].(string); ok {
-		c.UserID = &userID
-		delete(values, "userId")
-	}
+// ContactUpdate is a partial update of a contact: only the fields that are set are sent, all others are left
+// unchanged. Fields set to Null are cleared. The contact is identified by its email or userId.
+type ContactUpdate struct {
+	// The contact's email address. If the contact is identified by UserID, this updates its email address.
+	Email string
+	// The contact's unique user ID. If Email is set as well, this updates its user ID.
+	UserID string
+	// The contact's first name.
+	FirstName Optional[string]
+	// The contact's last name.
+	LastName Optional[string]
+	// The source the contact was created from.
+	Source Optional[string]
+	// Whether the contact will receive campaign and loops emails.
+	Subscribed Optional[bool]
+	// The contact's user group (used to segemnt users when sending emails).
+	UserGroup Optional[string]
+	// Mailing lists to subscribe the contact to (true) or unsubscribe it from (false). Lists not included are left
+	// unchanged.
+	MailingLists map[string]bool
+	// Custom properties to update. Properties not included are left unchanged, and properties with a nil value
+	// are cleared. time.Time values are sent as dates.
+	Properties map[string]any
+}
 
-	mailingLists, ok := values["mailingLists"].(map[string]any)
-	if ok {
-		c.MailingLists = make(map[string]bool)
-		for k, v := range mailingLists {
-			c.MailingLists[k] = v.(bool)
-		}
-		delete(values, "mailingLists")
-	}
+// contactUpdateFields are the names of the standard fields of a contact update, in sorted order.
+var contactUpdateFields = [...]string{
+	"email", "firstName", "lastName", "mailingLists", "source", "subscribed", "userGroup", "userId",
+}
 
-	if optInStatus, ok := values["optInStatus"].(string); ok {
-		status := OptInStatus(optInStatus)
-		c.OptInStatus = &status
-		delete(values, "optInStatus")
+// MarshalJSON encodes the fields of the update that are set, with custom properties inlined to the root object
+func (u *ContactUpdate) MarshalJSON() ([]byte, error) {
+	return marshalAppender(u)
+}
+
+func (u *ContactUpdate) appendJSON(dst []byte) ([]byte, error) {
+	if err := checkReservedProperties(u.Properties); err != nil {
+		return nil, err
 	}
+	return appendObjectWithProperties(dst, contactUpdateFields[:], u.appendField, u.Properties)
+}
 
-	c.Properties = make(map[string]any)
-	maps.Copy(c.Properties, values)
+// appendField appends the standard field with the given name, if it is set.
+func (u *ContactUpdate) appendField(w *objectWriter, name string) error {
+	switch name {
+	case "email":
+		if u.Email != "" {
+			w.string(name, u.Email)
+		}
+	case "userId":
+		if u.UserID != "" {
+			w.string(name, u.UserID)
+		}
+	case "firstName":
+		return appendOptional(w, name, u.FirstName)
+	case "lastName":
+		return appendOptional(w, name, u.LastName)
+	case "source":
+		return appendOptional(w, name, u.Source)
+	case "subscribed":
+		return appendOptional(w, name, u.Subscribed)
+	case "userGroup":
+		return appendOptional(w, name, u.UserGroup)
+	case "mailingLists":
+		if u.MailingLists != nil {
+			w.key(name)
+			w.buf = appendJSONBoolMap(w.buf, u.MailingLists)
+		}
+	}
 	return nil
 }
 
@@ -156,13 +328,42 @@ type ContactIdentifier struct {
 	UserID *string `json:"userId,omitempty"`
 }
 
+// PropertyType is the type of a contact property.
+type PropertyType string
+
+const (
+	PropertyTypeString  PropertyType = "string"
+	PropertyTypeNumber  PropertyType = "number"
+	PropertyTypeBoolean PropertyType = "boolean"
+	PropertyTypeDate    PropertyType = "date"
+)
+
+var propertyTypes = []PropertyType{PropertyTypeString, PropertyTypeNumber, PropertyTypeBoolean, PropertyTypeDate}
+
+// MarshalText encodes the property type, rejecting unknown types.
+func (t PropertyType) MarshalText() ([]byte, error) {
+	if !slices.Contains(propertyTypes, t) {
+		return nil, fmt.Errorf("invalid property type: %q", string(t))
+	}
+	return []byte(t), nil
+}
+
+// UnmarshalText decodes a property type, rejecting unknown types.
+func (t *PropertyType) UnmarshalText(text []byte) error {
+	if !slices.Contains(propertyTypes, PropertyType(text)) {
+		return fmt.Errorf("invalid property type: %q", text)
+	}
+	*t = PropertyType(text)
+	return nil
+}
+
 type ContactProperty struct {
 	// The property's name key
 	Key string `json:"key"`
 	// The human-friendly label for this property
 	Label string `json:"label"`
-	// The type of property (one of string, number, boolean or date)
-	Type string `json:"type"`
+	// The type of property
+	Type PropertyType `json:"type"`
 }
 
 // Deprecated: Use ContactProperty instead.
@@ -171,8 +372,25 @@ type CustomField = ContactProperty
 type ContactPropertyCreate struct {
 	// The property's name key (must be in camelCase, like `planName`)
 	Name string `json:"name"`
-	// The type of property (one of string, number, boolean or date)
-	Type string `json:"type"`
+	// The type of property
+	Type PropertyType `json:"type"`
+}
+
+// camelCase matches property names in camelCase, like `planName`.
+var camelCase = regexp.MustCompile(`^[a-z][a-zA-Z0-9]*$`)
+
+// validate checks that the name is camelCase and not reserved, and that the type is known.
+func (p *ContactPropertyCreate) validate() error {
+	if !camelCase.MatchString(p.Name) {
+		return fmt.Errorf("%w: %q must be in camelCase, like planName", ErrInvalidPropertyName, p.Name)
+	}
+	if slices.Contains(reservedPropertyNames, p.Name) {
+		return &ReservedPropertyError{Key: p.Name}
+	}
+	if _, err := p.Type.MarshalText(); err != nil {
+		return err
+	}
+	return nil
 }
 
 type MailingList struct {
@@ -194,14 +412,46 @@ type Event struct {
 	UserID *string `json:"userId,omitempty"`
 	// The name of the event
 	EventName string `json:"eventName"`
-	// Properties to update the contact with, including custom properties.
+	// Properties to update the contact with, including custom properties. time.Time values are sent as dates.
 	ContactProperties map[string]any `json:"contactProperties,omitempty"`
-	// Event properties, made available in emails triggered by the event.
+	// Event properties, made available in emails triggered by the event. time.Time values are sent as dates.
 	EventProperties *map[string]any `json:"eventProperties,omitempty"`
 	// An object of mailing list IDs and boolean subscription statuses.
 	MailingLists *map[string]any `json:"mailingLists,omitempty"`
 }
 
+// MarshalJSON overrides the default json marshaller to encode time.Time property values in the Loops date format.
+func (e *Event) MarshalJSON() ([]byte, error) {
+	type event Event // without methods, to not recurse into MarshalJSON
+	encoded := event(*e)
+	encoded.ContactProperties = withLoopsDates(e.ContactProperties)
+	if e.EventProperties != nil {
+		eventProperties := withLoopsDates(*e.EventProperties)
+		encoded.EventProperties = &eventProperties
+	}
+	return json.Marshal(&encoded)
+}
+
+// withLoopsDates returns a copy of the properties with time.Time values converted to the Loops date format, unix
+// milliseconds. If there are none, the properties are returned as is.
+func withLoopsDates(properties map[string]any) map[string]any {
+	var converted map[string]any
+	for key, value := range properties {
+		t, ok := value.(time.Time)
+		if !ok {
+			continue
+		}
+		if converted == nil {
+			converted = maps.Clone(properties)
+		}
+		converted[key] = t.UnixMilli()
+	}
+	if converted == nil {
+		return properties
+	}
+	return converted
+}
+
 type TransactionalEmail struct {
 	// The ID of the transactional email to send.
 	TransactionalID string `json:"transactionalId"`
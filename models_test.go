@@ -2,7 +2,9 @@ package loops
 
 import (
 	"encoding/json"
+	"maps"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -25,6 +27,105 @@ func TestContactMarshalJSONCustomPropertiesInlined(t *testing.T) {
 	assert.JSONEq(t, `{"id":"123","email":"test@example.com","subscribed":true,"favoriteColor":"blue","mailingLists":{"list_123":true}}`, string(data))
 }
 
+func TestMarshalJSONDateProperties(t *testing.T) {
+	launch := time.Date(1969, 7, 16, 13, 32, 0, 0, time.UTC)
+
+	data, err := json.Marshal(&Contact{Email: "test@example.com", Properties: Properties{"launchedAt": launch}})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":"","email":"test@example.com","subscribed":false,"launchedAt":-14552880000}`, string(data))
+
+	data, err = json.Marshal(&ContactUpdate{Email: "test@example.com", Properties: map[string]any{"launchedAt": launch}})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"email":"test@example.com","launchedAt":-14552880000}`, string(data))
+
+	event := &Event{
+		Email:             String("test@example.com"),
+		EventName:         "launch",
+		ContactProperties: map[string]any{"launchedAt": launch, "role": "Commander"},
+		EventProperties:   &map[string]any{"at": launch},
+	}
+	data, err = json.Marshal(event)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"email":"test@example.com","eventName":"launch",
+		"contactProperties":{"launchedAt":-14552880000,"role":"Commander"},"eventProperties":{"at":-14552880000}}`, string(data))
+	assert.Equal(t, launch, event.ContactProperties["launchedAt"], "the event is not modified")
+}
+
+func TestPropertyTypeJSON(t *testing.T) {
+	var property ContactProperty
+	require.NoError(t, json.Unmarshal([]byte(`{"key":"launchedAt","label":"Launched at","type":"date"}`), &property))
+	assert.Equal(t, PropertyTypeDate, property.Type)
+	require.ErrorContains(t, json.Unmarshal([]byte(`{"key":"tags","type":"list"}`), &property), `invalid property type: "list"`)
+
+	data, err := json.Marshal(&ContactPropertyCreate{Name: "planName", Type: PropertyTypeBoolean})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name":"planName","type":"boolean"}`, string(data))
+	_, err = json.Marshal(&ContactPropertyCreate{Name: "planName"})
+	require.Error(t, err)
+}
+
+func TestContactUpdateMarshalJSONOnlySetFields(t *testing.T) {
+	u := ContactUpdate{
+		Email:     "test@example.com",
+		UserGroup: Set("Astronauts"),
+	}
+	data, err := json.Marshal(&u)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"email":"test@example.com","userGroup":"Astronauts"}`, string(data))
+
+	u = ContactUpdate{
+		UserID:       "user_123",
+		FirstName:    Set("Neil"),
+		Subscribed:   Set(false),
+		MailingLists: map[string]bool{"list_123": false},
+		Properties:   map[string]any{"favoriteColor": "blue"},
+	}
+	data, err = json.Marshal(&u)
+	require.NoError(t, err)
+	assert.Equal(t, `{"favoriteColor":"blue","firstName":"Neil","mailingLists":{"list_123":false},"subscribed":false,"userId":"user_123"}`, string(data))
+}
+
+func TestContactUpdateMarshalJSONNull(t *testing.T) {
+	u := ContactUpdate{
+		Email:      "test@example.com",
+		FirstName:  Null[string](),
+		UserGroup:  Set("Astronauts"),
+		Properties: map[string]any{"favoriteColor": nil},
+	}
+	data, err := json.Marshal(&u)
+	require.NoError(t, err)
+	assert.Equal(t, `{"email":"test@example.com","favoriteColor":null,"firstName":null,"userGroup":"Astronauts"}`, string(data))
+}
+
+func TestOptional(t *testing.T) {
+	var unset Optional[string]
+	assert.False(t, unset.IsSet())
+	assert.False(t, unset.IsNull())
+
+	value, ok := Set("Neil").Get()
+	assert.True(t, ok)
+	assert.Equal(t, "Neil", value)
+
+	null := Null[string]()
+	assert.True(t, null.IsSet())
+	assert.True(t, null.IsNull())
+	_, ok = null.Get()
+	assert.False(t, ok)
+}
+
+func TestContactMarshalJSONReservedProperty(t *testing.T) {
+	c := Contact{Email: "test@example.com", Properties: map[string]any{"subscribed": true, "email": "other@example.com"}}
+	_, err := json.Marshal(&c)
+	require.ErrorIs(t, err, ErrReservedProperty)
+	var reservedErr *ReservedPropertyError
+	require.ErrorAs(t, err, &reservedErr)
+	assert.Equal(t, "email", reservedErr.Key)
+
+	u := ContactUpdate{Email: "test@example.com", Properties: map[string]any{"id": "123"}}
+	_, err = json.Marshal(&u)
+	require.ErrorIs(t, err, ErrReservedProperty)
+}
+
 func TestContactUnmarshalJSONCustomPropertiesInlined(t *testing.T) {
 	c := Contact{}
 
@@ -42,3 +143,186 @@ func TestContactUnmarshalJSONCustomPropertiesInlined(t *testing.T) {
 	assert.True(t, ok)
 	assert.True(t, list123)
 }
+
+// marshalContactViaMap is the reference encoding of a contact, building an intermediate map which is then encoded
+// by encoding/json.
+func marshalContactViaMap(c *Contact) ([]byte, error) {
+	data := map[string]any{
+		"id":         c.ID,
+		"email":      c.Email,
+		"subscribed": c.Subscribed,
+	}
+	if c.FirstName != nil {
+		data["firstName"] = *c.FirstName
+	}
+	if c.LastName != nil {
+		data["lastName"] = *c.LastName
+	}
+	if c.UserID != nil {
+		data["userId"] = *c.UserID
+	}
+	if c.MailingLists != nil {
+		data["mailingLists"] = c.MailingLists
+	}
+	maps.Copy(data, c.Properties)
+	return json.Marshal(data)
+}
+
+func benchmarkContact() *Contact {
+	return &Contact{
+		Email:      "neil.armstrong@moon.space",
+		FirstName:  String("Neil"),
+		LastName:   String("Armstrong"),
+		UserID:     String("user_123"),
+		Subscribed: true,
+		MailingLists: map[string]bool{
+			"cm3n274xf027h0mi33t4qhrdg": true,
+			"cm6gb0ku002d00kiig98e153r": false,
+		},
+		Properties: map[string]any{
+			"companyRole":  "Astronaut <Commander> & Pilot",
+			"missions":     3,
+			"hoursInSpace": 206.2,
+			"moonWalker":   true,
+			"nickname":     nil,
+			"crew":         []string{"Buzz Aldrin", "Michael Collins"},
+		},
+	}
+}
+
+func TestContactMarshalJSONMatchesEncodingJSON(t *testing.T) {
+	contacts := []*Contact{
+		benchmarkContact(),
+		{},
+		{
+			Email:      "test@example.com",
+			FirstName:  String("quotes \" and \\ backslashes\n\t\b\f\x01 \u2028 \U0001F680"),
+			Properties: map[string]any{"tiny": 1e-7, "huge": 1e21, "float32": float32(0.1), "zero": 0.0},
+		},
+		{
+			Email:      "test@example.com",
+			Properties: map[string]any{"a": 1, "zzz": map[string]any{"nested": true}},
+		},
+	}
+	for _, c := range contacts {
+		expected, err := marshalContactViaMap(c)
+		require.NoError(t, err)
+		actual, err := json.Marshal(c)
+		require.NoError(t, err)
+		assert.Equal(t, string(expected), string(actual))
+	}
+}
+
+func TestContactUnmarshalJSONMatchesEncodingJSON(t *testing.T) {
+	data := []byte(`{ "id" : "123", "email":"test@example.com", "subscribed":false, "userGroup": "Astro\"nautsé",
+		"mailingLists": {"a": true, "b": false}, "optInStatus": "pending", "firstName": null,
+		"nested": {"a": [1, 2, {"b": "}"}]}, "number": -1.5e3, "empty": "", "list": [], "flag": true }`)
+
+	c := Contact{}
+	require.NoError(t, json.Unmarshal(data, &c))
+	assert.Equal(t, "123", c.ID)
+	assert.Equal(t, "test@example.com", c.Email)
+	assert.False(t, c.Subscribed)
+	assert.Equal(t, "Astro\"nautsé", *c.UserGroup)
+	assert.Equal(t, map[string]bool{"a": true, "b": false}, c.MailingLists)
+	assert.Equal(t, OptInStatusPending, *c.OptInStatus)
+	assert.Nil(t, c.FirstName)
+
+	expected := map[string]any{}
+	require.NoError(t, json.Unmarshal(data, &expected))
+	for _, field := range []string{"id", "email", "subscribed", "userGroup", "mailingLists", "optInStatus", "firstName"} {
+		delete(expected, field)
+	}
+	assert.Equal(t, Properties(expected), c.Properties)
+}
+
+func TestContactUnmarshalJSONNulls(t *testing.T) {
+	c := Contact{}
+	require.NoError(t, json.Unmarshal([]byte(`{"id":"123","email":"test@example.com","subscribed":true,
+		"firstName":null,"userGroup":null,"mailingLists":null,"favoriteColor":null}`), &c))
+	assert.Nil(t, c.FirstName)
+	assert.Nil(t, c.UserGroup)
+	assert.Nil(t, c.MailingLists)
+	assert.Equal(t, Properties{"favoriteColor": nil}, c.Properties, "a cleared custom property is kept as nil")
+}
+
+func TestContactUnmarshalJSONMissingFields(t *testing.T) {
+	c := Contact{}
+	require.Error(t, json.Unmarshal([]byte(`{"email":"test@example.com","subscribed":true}`), &c))
+	require.Error(t, json.Unmarshal([]byte(`{"id":"123","subscribed":true}`), &c))
+	require.Error(t, json.Unmarshal([]byte(`{"id":"123","email":"test@example.com"}`), &c))
+	require.Error(t, c.UnmarshalJSON([]byte(`{"id":"123"`)))
+	require.Error(t, json.Unmarshal([]byte(`{"id":null,"email":"test@example.com","subscribed":true}`), &c))
+	require.Error(t, json.Unmarshal([]byte(`{"id":"123","email":"test@example.com","subscribed":true,
+		"mailingLists":{"list_1":null}}`), &c))
+}
+
+func TestContactDecodeLenient(t *testing.T) {
+	c := Contact{}
+	require.NoError(t, c.decode([]byte(`{"id":"123","email":null,"firstName":5,
+		"mailingLists":{"list_1":true,"list_2":null,"list_3":"yes"}}`), decodeOptions{lenient: true}))
+	assert.Equal(t, "123", c.ID)
+	assert.Equal(t, map[string]bool{"list_1": true}, c.MailingLists)
+	assert.Nil(t, c.FirstName)
+	assert.Empty(t, c.Properties, "standard fields of an unexpected type are no custom properties")
+	assert.Equal(t, []DecodeWarning{
+		{Field: "firstName", Issue: "unexpected type, ignored"},
+		{Field: "mailingLists.list_2", Issue: "subscription status is not a boolean, list skipped"},
+		{Field: "mailingLists.list_3", Issue: "subscription status is not a boolean, list skipped"},
+		{Field: "email", Issue: "missing or invalid"},
+		{Field: "subscribed", Issue: "missing or invalid"},
+	}, c.DecodeWarnings)
+
+	require.Error(t, c.decode([]byte(`{"id":"123"`), decodeOptions{lenient: true}), "invalid JSON fails even in lenient mode")
+}
+
+func TestContactDecodedMarshalsAgain(t *testing.T) {
+	c := Contact{}
+	require.NoError(t, c.UnmarshalJSON([]byte(`{"id":"123","email":"test@example.com","subscribed":true,
+		"firstName":5,"userGroup":["a"],"createdAt":"2024-01-01","favoriteColor":"blue"}`)))
+	assert.Len(t, c.DecodeWarnings, 2)
+	assert.Equal(t, Properties{"createdAt": "2024-01-01", "favoriteColor": "blue"}, c.Properties)
+
+	data, err := json.Marshal(&c)
+	require.NoError(t, err, "a decoded contact can be sent back, e.g. to update it")
+	assert.JSONEq(t, `{"id":"123","email":"test@example.com","subscribed":true,"createdAt":"2024-01-01",
+		"favoriteColor":"blue"}`, string(data))
+}
+
+func BenchmarkContactMarshalJSON(b *testing.B) {
+	c := benchmarkContact()
+	b.ReportAllocs()
+	for range b.N {
+		_, _ = JSONCodec{}.Marshal(c)
+	}
+}
+
+// BenchmarkContactMarshalJSONViaMap is the baseline for BenchmarkContactMarshalJSON.
+func BenchmarkContactMarshalJSONViaMap(b *testing.B) {
+	c := benchmarkContact()
+	b.ReportAllocs()
+	for range b.N {
+		_, _ = marshalContactViaMap(c)
+	}
+}
+
+func BenchmarkContactUnmarshalJSON(b *testing.B) {
+	data, err := json.Marshal(benchmarkContact())
+	require.NoError(b, err)
+	b.ReportAllocs()
+	for range b.N {
+		c := Contact{}
+		_ = c.UnmarshalJSON(data)
+	}
+}
+
+// BenchmarkContactUnmarshalJSONViaMap is the baseline for BenchmarkContactUnmarshalJSON.
+func BenchmarkContactUnmarshalJSONViaMap(b *testing.B) {
+	data, err := json.Marshal(benchmarkContact())
+	require.NoError(b, err)
+	b.ReportAllocs()
+	for range b.N {
+		values := map[string]any{}
+		_ = json.Unmarshal(data, &values)
+	}
+}
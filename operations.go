@@ -0,0 +1,204 @@
+package loops
+
+import (
+	"net/http"
+	"reflect"
+)
+
+// Operation identifies an API operation of the client.
+type Operation string
+
+const (
+	OperationCreateContact           Operation = "createContact"
+	OperationUpdateContact           Operation = "updateContact"
+	OperationFindContact             Operation = "findContact"
+	OperationDeleteContact           Operation = "deleteContact"
+	OperationGetMailingLists         Operation = "getMailingLists"
+	OperationSendEvent               Operation = "sendEvent"
+	OperationSendTransactionalEmail  Operation = "sendTransactionalEmail"
+	OperationGetContactProperties    Operation = "getContactProperties"
+	OperationCreateContactProperty   Operation = "createContactProperty"
+	OperationGetCustomFields         Operation = "getCustomFields"
+	OperationGetDedicatedSendingIPs  Operation = "getDedicatedSendingIPs"
+	OperationListTransactionalEmails Operation = "listTransactionalEmails"
+	OperationTestAPIKey              Operation = "testAPIKey"
+	// OperationRawRead identifies GET requests sent using Client.Do.
+	OperationRawRead Operation = "rawRead"
+	// OperationRawWrite identifies all other requests sent using Client.Do.
+	OperationRawWrite Operation = "rawWrite"
+)
+
+// OperationDescriptor describes an API endpoint wrapped by the client, e.g. for generating documentation or CLIs,
+// or deciding which calls are safe to retry.
+type OperationDescriptor struct {
+	// The operation, which is also the name of the endpoint, e.g. "createContact".
+	Name Operation
+	// The HTTP method of the endpoint.
+	Method string
+	// The path of the endpoint, relative to the API URL.
+	Path string
+	// Whether sending the same request multiple times has the same effect as sending it once.
+	Idempotent bool
+	// The type of the request, which is sent as JSON body, or as query parameters for GET requests.
+	// Nil if the endpoint has no parameters.
+	Request reflect.Type
+	// The type of partial requests to the endpoint, which only contain the fields to change, e.g. *ContactUpdate
+	// sent by Client.UpdateContactFields. Nil if there are none.
+	PartialRequest reflect.Type
+	// The type the response is unmarshaled into.
+	Response reflect.Type
+	// If the endpoint is deprecated, a note on what to use instead. Empty otherwise.
+	Deprecated string
+	// The URL of the endpoint's API reference.
+	Documentation string
+}
+
+// operationDescriptors describes all endpoints wrapped by the client, which the client methods are built from.
+var operationDescriptors = []OperationDescriptor{
+	{
+		Name:          OperationCreateContact,
+		Method:        http.MethodPost,
+		Path:          "/contacts/create",
+		Request:       reflect.TypeFor[*Contact](),
+		Response:      reflect.TypeFor[*IDResponse](),
+		Documentation: "https://loops.so/docs/api-reference/create-contact",
+	},
+	{
+		Name:           OperationUpdateContact,
+		Method:         http.MethodPut,
+		Path:           "/contacts/update",
+		Idempotent:     true,
+		Request:        reflect.TypeFor[*Contact](),
+		PartialRequest: reflect.TypeFor[*ContactUpdate](),
+		Response:       reflect.TypeFor[*IDResponse](),
+		Documentation:  "https://loops.so/docs/api-reference/update-contact",
+	},
+	{
+		Name:          OperationFindContact,
+		Method:        http.MethodGet,
+		Path:          "/contacts/find",
+		Idempotent:    true,
+		Request:       reflect.TypeFor[*ContactIdentifier](),
+		Response:      reflect.TypeFor[[]*Contact](),
+		Documentation: "https://loops.so/docs/api-reference/find-contact",
+	},
+	{
+		Name:          OperationDeleteContact,
+		Method:        http.MethodPost,
+		Path:          "/contacts/delete",
+		Idempotent:    true,
+		Request:       reflect.TypeFor[*ContactIdentifier](),
+		Response:      reflect.TypeFor[*MessageResponse](),
+		Documentation: "https://loops.so/docs/api-reference/delete-contact",
+	},
+	{
+		Name:          OperationGetMailingLists,
+		Method:        http.MethodGet,
+		Path:          "/lists",
+		Idempotent:    true,
+		Response:      reflect.TypeFor[[]*MailingList](),
+		Documentation: "https://loops.so/docs/api-reference/get-mailing-lists",
+	},
+	{
+		Name:          OperationSendEvent,
+		Method:        http.MethodPost,
+		Path:          "/events/send",
+		Request:       reflect.TypeFor[*Event](),
+		Response:      reflect.TypeFor[*MessageResponse](),
+		Documentation: "https://loops.so/docs/api-reference/send-event",
+	},
+	{
+		Name:          OperationSendTransactionalEmail,
+		Method:        http.MethodPost,
+		Path:          "/transactional",
+		Request:       reflect.TypeFor[*TransactionalEmail](),
+		Response:      reflect.TypeFor[*MessageResponse](),
+		Documentation: "https://loops.so/docs/api-reference/send-transactional-email",
+	},
+	{
+		Name:          OperationGetContactProperties,
+		Method:        http.MethodGet,
+		Path:          "/contacts/properties",
+		Idempotent:    true,
+		Request:       reflect.TypeFor[ContactPropertyListOptions](),
+		Response:      reflect.TypeFor[[]*ContactProperty](),
+		Documentation: "https://loops.so/docs/api-reference/list-contact-properties",
+	},
+	{
+		Name:          OperationCreateContactProperty,
+		Method:        http.MethodPost,
+		Path:          "/contacts/properties",
+		Request:       reflect.TypeFor[*ContactPropertyCreate](),
+		Response:      reflect.TypeFor[*SuccessResponse](),
+		Documentation: "https://loops.so/docs/api-reference/create-contact-property",
+	},
+	{
+		Name:       OperationGetCustomFields,
+		Method:     http.MethodGet,
+		Path:       "/contacts/customFields",
+		Idempotent: true,
+		Response:   reflect.TypeFor[[]*ContactProperty](),
+		Deprecated: "Use GetContactProperties instead.",
+	},
+	{
+		Name:          OperationGetDedicatedSendingIPs,
+		Method:        http.MethodGet,
+		Path:          "/dedicated-sending-ips",
+		Idempotent:    true,
+		Response:      reflect.TypeFor[[]string](),
+		Documentation: "https://loops.so/docs/api-reference/list-dedicated-sending-ips",
+	},
+	{
+		Name:          OperationListTransactionalEmails,
+		Method:        http.MethodGet,
+		Path:          "/transactional",
+		Idempotent:    true,
+		Request:       reflect.TypeFor[ListTransactionalEmailsOptions](),
+		Response:      reflect.TypeFor[*TransactionalEmailList](),
+		Documentation: "https://loops.so/docs/api-reference/list-transactional-emails",
+	},
+	{
+		Name:          OperationTestAPIKey,
+		Method:        http.MethodGet,
+		Path:          "/api-key",
+		Idempotent:    true,
+		Response:      reflect.TypeFor[*APIKeyInfo](),
+		Documentation: "https://loops.so/docs/api-reference/api-key",
+	},
+}
+
+// Operations returns the descriptors of all endpoints wrapped by the client. Requests sent using Client.Do are not
+// included, since they have no fixed endpoint.
+func Operations() []OperationDescriptor {
+	descriptors := make([]OperationDescriptor, len(operationDescriptors))
+	copy(descriptors, operationDescriptors)
+	return descriptors
+}
+
+// LookupOperation returns the descriptor of the given operation, and false if there is none.
+func LookupOperation(op Operation) (OperationDescriptor, bool) {
+	for _, d := range operationDescriptors {
+		if d.Name == op {
+			return d, true
+		}
+	}
+	return OperationDescriptor{}, false
+}
+
+// allOperations lists all operations of the client.
+var allOperations = func() []Operation {
+	ops := make([]Operation, 0, len(operationDescriptors)+2)
+	for _, d := range operationDescriptors {
+		ops = append(ops, d.Name)
+	}
+	return append(ops, OperationRawRead, OperationRawWrite)
+}()
+
+// isReadOperation returns whether the given operation doesn't modify anything.
+func isReadOperation(op Operation) bool {
+	if op == OperationRawRead {
+		return true
+	}
+	d, ok := LookupOperation(op)
+	return ok && d.Method == http.MethodGet
+}
@@ -0,0 +1,105 @@
+package loops
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOperationDescriptorsMatchRequests(t *testing.T) {
+	email := String("test@example.com")
+	calls := map[Operation]func(ctx context.Context, c *Client) error{
+		OperationCreateContact: func(ctx context.Context, c *Client) error {
+			_, err := c.CreateContact(ctx, &Contact{Email: *email})
+			return err
+		},
+		OperationUpdateContact: func(ctx context.Context, c *Client) error {
+			_, err := c.UpdateContact(ctx, &Contact{Email: *email})
+			return err
+		},
+		OperationFindContact: func(ctx context.Context, c *Client) error {
+			_, err := c.FindContact(ctx, &ContactIdentifier{Email: email})
+			return err
+		},
+		OperationDeleteContact: func(ctx context.Context, c *Client) error {
+			return c.DeleteContact(ctx, &ContactIdentifier{Email: email})
+		},
+		OperationGetMailingLists: func(ctx context.Context, c *Client) error {
+			_, err := c.GetMailingLists(ctx)
+			return err
+		},
+		OperationSendEvent: func(ctx context.Context, c *Client) error {
+			return c.SendEvent(ctx, &Event{Email: email, EventName: "signup"})
+		},
+		OperationSendTransactionalEmail: func(ctx context.Context, c *Client) error {
+			return c.SendTransactionalEmail(ctx, &TransactionalEmail{TransactionalID: "tx", Email: *email})
+		},
+		OperationGetContactProperties: func(ctx context.Context, c *Client) error {
+			_, err := c.GetContactProperties(ctx, ContactPropertyListOptions{})
+			return err
+		},
+		OperationCreateContactProperty: func(ctx context.Context, c *Client) error {
+			return c.CreateContactProperty(ctx, &ContactPropertyCreate{Name: "planName", Type: PropertyTypeString})
+		},
+		OperationGetCustomFields: func(ctx context.Context, c *Client) error {
+			_, err := c.GetCustomFields(ctx)
+			return err
+		},
+		OperationGetDedicatedSendingIPs: func(ctx context.Context, c *Client) error {
+			_, err := c.GetDedicatedSendingIPs(ctx)
+			return err
+		},
+		OperationListTransactionalEmails: func(ctx context.Context, c *Client) error {
+			_, err := c.ListTransactionalEmails(ctx, ListTransactionalEmailsOptions{})
+			return err
+		},
+		OperationTestAPIKey: func(ctx context.Context, c *Client) error {
+			_, err := c.TestAPIKey(ctx)
+			return err
+		},
+	}
+
+	require.Len(t, Operations(), len(calls))
+	for _, descriptor := range Operations() {
+		t.Run(string(descriptor.Name), func(t *testing.T) {
+			call, ok := calls[descriptor.Name]
+			require.True(t, ok, "no call for operation")
+
+			var method, path string
+			client, err := NewClient(WithHTTPClient(httpClientFunc(func(req *http.Request) (*http.Response, error) {
+				method, path = req.Method, req.URL.Path
+				return jsonResponse(http.StatusInternalServerError, `{"message":"not implemented"}`), nil
+			})))
+			require.NoError(t, err)
+
+			_ = call(context.Background(), client)
+			assert.Equal(t, descriptor.Method, method)
+			assert.Equal(t, "/api/v1"+descriptor.Path, path)
+			assert.NotNil(t, descriptor.Response)
+		})
+	}
+}
+
+func TestLookupOperation(t *testing.T) {
+	descriptor, ok := LookupOperation(OperationGetCustomFields)
+	require.True(t, ok)
+	assert.Equal(t, "/contacts/customFields", descriptor.Path)
+	assert.NotEmpty(t, descriptor.Deprecated)
+
+	descriptor, ok = LookupOperation(OperationUpdateContact)
+	require.True(t, ok)
+	assert.Equal(t, reflect.TypeFor[*Contact](), descriptor.Request)
+	assert.Equal(t, reflect.TypeFor[*ContactUpdate](), descriptor.PartialRequest, "sent by UpdateContactFields")
+
+	_, ok = LookupOperation(OperationRawRead)
+	assert.False(t, ok, "raw requests have no fixed endpoint")
+}
+
+func TestOperationsReturnsCopy(t *testing.T) {
+	Operations()[0].Path = "/modified"
+	assert.Equal(t, "/contacts/create", Operations()[0].Path)
+}
@@ -0,0 +1,47 @@
+package loops
+
+// Optional is a value that is either unset, set to a value, or set to null, e.g. a field of a partial update that
+// should only be sent if it was set explicitly, or cleared. The zero value is unset.
+type Optional[T any] struct {
+	value T
+	set   bool
+	null  bool
+}
+
+// Set returns an Optional set to the given value.
+func Set[T any](v T) Optional[T] {
+	return Optional[T]{value: v, set: true}
+}
+
+// Null returns an Optional set to null, which is sent as JSON null to clear the value.
+func Null[T any]() Optional[T] {
+	return Optional[T]{set: true, null: true}
+}
+
+// Get returns the value, and whether it is set to a value (rather than unset or null).
+func (o Optional[T]) Get() (T, bool) {
+	return o.value, o.set && !o.null
+}
+
+// IsSet returns whether the value is set, either to a value or to null.
+func (o Optional[T]) IsSet() bool {
+	return o.set
+}
+
+// IsNull returns whether the value is set to null.
+func (o Optional[T]) IsNull() bool {
+	return o.null
+}
+
+// appendOptional appends the field with the given name, if the value is set. A null value is appended as null.
+func appendOptional[T any](w *objectWriter, name string, o Optional[T]) error {
+	if !o.set {
+		return nil
+	}
+	if o.null {
+		w.key(name)
+		w.buf = append(w.buf, "null"...)
+		return nil
+	}
+	return w.value(name, o.value)
+}
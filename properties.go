@@ -0,0 +1,171 @@
+package loops
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+// ErrPropertyNotSet is returned by the accessors of Properties for properties that are not set, or null.
+var ErrPropertyNotSet = errors.New("custom property not set")
+
+// ErrPropertyMismatch is returned (wrapped in a PropertyMismatchError) by the accessors of Properties for properties
+// whose value can't be converted to the requested type.
+var ErrPropertyMismatch = errors.New("custom property has an unexpected type")
+
+// PropertyMismatchError is returned for a custom property whose value can't be converted to the requested type,
+// such as a string property read with Properties.Int64.
+type PropertyMismatchError struct {
+	Key string
+	// The requested type, e.g. "int64".
+	Expected string
+	Value    any
+}
+
+func (e *PropertyMismatchError) Error() string {
+	return fmt.Sprintf("custom property %q is not a valid %s: %v (%T)", e.Key, e.Expected, e.Value, e.Value)
+}
+
+func (e *PropertyMismatchError) Is(target error) bool {
+	return target == ErrPropertyMismatch
+}
+
+// Properties are the custom properties of a contact, by name. Decoded numbers are float64, or json.Number if the
+// client is configured WithExactNumbers. The accessors convert values to the requested type, so callers don't need
+// type assertions.
+type Properties map[string]any
+
+// String returns the string property with the given name.
+func (p Properties) String(key string) (string, error) {
+	value, err := p.get(key)
+	if err != nil {
+		return "", err
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", &PropertyMismatchError{Key: key, Expected: "string", Value: value}
+	}
+	return s, nil
+}
+
+// Int64 returns the number property with the given name as integer. Numbers with a fractional part are rejected.
+func (p Properties) Int64(key string) (int64, error) {
+	value, err := p.get(key)
+	if err != nil {
+		return 0, err
+	}
+	mismatch := &PropertyMismatchError{Key: key, Expected: "int64", Value: value}
+	if f, ok := value.(float32); ok {
+		value = float64(f)
+	}
+
+	switch v := value.(type) {
+	case json.Number:
+		n, err := v.Int64()
+		if err != nil {
+			return 0, mismatch
+		}
+		return n, nil
+	case float64:
+		// -2^63 is exactly representable, 2^63 is the first float64 out of range
+		if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+			return 0, mismatch
+		}
+		return int64(v), nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() { //nolint:exhaustive // all other kinds are no integers
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if rv.Uint() > math.MaxInt64 {
+			return 0, mismatch
+		}
+		return int64(rv.Uint()), nil //nolint:gosec // checked for overflow above
+	default:
+		return 0, mismatch
+	}
+}
+
+// Float64 returns the number property with the given name as floating point number.
+func (p Properties) Float64(key string) (float64, error) {
+	value, err := p.get(key)
+	if err != nil {
+		return 0, err
+	}
+	mismatch := &PropertyMismatchError{Key: key, Expected: "float64", Value: value}
+
+	switch v := value.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return 0, mismatch
+		}
+		return f, nil
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() { //nolint:exhaustive // all other kinds are no numbers
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), nil
+	default:
+		return 0, mismatch
+	}
+}
+
+// Bool returns the boolean property with the given name.
+func (p Properties) Bool(key string) (bool, error) {
+	value, err := p.get(key)
+	if err != nil {
+		return false, err
+	}
+	b, ok := value.(bool)
+	if !ok {
+		return false, &PropertyMismatchError{Key: key, Expected: "bool", Value: value}
+	}
+	return b, nil
+}
+
+// Time returns the date property with the given name. Besides time.Time values, it accepts numbers as unix
+// timestamps in milliseconds, and strings in RFC 3339 or YYYY-MM-DD format.
+func (p Properties) Time(key string) (time.Time, error) {
+	value, err := p.get(key)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, time.DateOnly} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, nil
+			}
+		}
+	default:
+		if millis, err := p.Int64(key); err == nil {
+			return time.UnixMilli(millis).UTC(), nil
+		}
+	}
+	return time.Time{}, &PropertyMismatchError{Key: key, Expected: "time", Value: value}
+}
+
+// get returns the value of the property with the given name, or ErrPropertyNotSet if it is not set or null.
+func (p Properties) get(key string) (any, error) {
+	value := p[key]
+	if value == nil {
+		return nil, fmt.Errorf("%w: %q", ErrPropertyNotSet, key)
+	}
+	return value, nil
+}
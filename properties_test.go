@@ -0,0 +1,79 @@
+package loops
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPropertiesAccessors(t *testing.T) {
+	properties := Properties{
+		"role":      "Astronaut",
+		"missions":  float64(3),
+		"exactId":   json.Number("9007199254740993"),
+		"count":     7,
+		"hours":     206.2,
+		"moon":      true,
+		"launchIso": "1969-07-16T13:32:00Z",
+		"launchDay": "1969-07-16",
+		"launchMs":  float64(-14552880000),
+		"nickname":  nil,
+	}
+
+	role, err := properties.String("role")
+	require.NoError(t, err)
+	assert.Equal(t, "Astronaut", role)
+
+	for key, expected := range map[string]int64{"missions": 3, "exactId": 9007199254740993, "count": 7} {
+		n, err := properties.Int64(key)
+		require.NoError(t, err, key)
+		assert.Equal(t, expected, n, key)
+	}
+
+	hours, err := properties.Float64("hours")
+	require.NoError(t, err)
+	assert.InDelta(t, 206.2, hours, 0)
+	count, err := properties.Float64("count")
+	require.NoError(t, err)
+	assert.InDelta(t, 7.0, count, 0)
+
+	moon, err := properties.Bool("moon")
+	require.NoError(t, err)
+	assert.True(t, moon)
+
+	launch := time.Date(1969, 7, 16, 13, 32, 0, 0, time.UTC)
+	for _, key := range []string{"launchIso", "launchMs"} {
+		tm, err := properties.Time(key)
+		require.NoError(t, err, key)
+		assert.True(t, launch.Equal(tm), key)
+	}
+	day, err := properties.Time("launchDay")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(1969, 7, 16, 0, 0, 0, 0, time.UTC), day)
+}
+
+func TestPropertiesAccessorErrors(t *testing.T) {
+	properties := Properties{"role": "Astronaut", "hours": 206.2, "huge": math.MaxFloat64, "nickname": nil}
+
+	_, err := properties.String("missing")
+	require.ErrorIs(t, err, ErrPropertyNotSet)
+	_, err = properties.String("nickname")
+	require.ErrorIs(t, err, ErrPropertyNotSet)
+	_, err = properties.String("hours")
+	require.ErrorIs(t, err, ErrPropertyMismatch)
+
+	_, err = properties.Int64("role")
+	require.ErrorIs(t, err, ErrPropertyMismatch)
+	_, err = properties.Int64("hours")
+	require.ErrorIs(t, err, ErrPropertyMismatch, "fractional numbers are no integers")
+	_, err = properties.Int64("huge")
+	require.ErrorIs(t, err, ErrPropertyMismatch)
+	_, err = properties.Bool("role")
+	require.EqualError(t, err, `custom property "role" is not a valid bool: Astronaut (string)`)
+	_, err = properties.Time("role")
+	require.ErrorIs(t, err, ErrPropertyMismatch)
+}
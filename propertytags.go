@@ -0,0 +1,249 @@
+package loops
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// PropertyMarshaler is implemented by types that encode themselves as custom contact property value, which must be a
+// string, number, bool, time.Time or nil.
+type PropertyMarshaler interface {
+	MarshalProperty() (any, error)
+}
+
+// PropertyUnmarshaler is implemented by types that decode themselves from a custom contact property value.
+type PropertyUnmarshaler interface {
+	UnmarshalProperty(value any) error
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// MarshalProperties converts a struct to custom contact properties, e.g. for Contact.Properties. Fields are mapped
+// by their `loops:"name"` tag, fields without a tag are ignored, and fields of embedded structs are mapped as if they
+// were fields of the outer struct. With the omitempty option, as in `loops:"planName,omitempty"`, zero values are
+// left out.
+//
+// Supported field types are strings, numbers, bools, time.Time, pointers to them, and types implementing
+// PropertyMarshaler or encoding.TextMarshaler. A nil pointer is mapped to nil, which clears the property.
+func MarshalProperties(v any) (map[string]any, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("properties must be a struct or a pointer to a struct, got %T", v)
+	}
+
+	fields, err := propertyFields(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+	properties := make(map[string]any, len(fields))
+	for _, field := range fields {
+		value, err := rv.FieldByIndexErr(field.index)
+		if err != nil {
+			continue // a field of an embedded struct pointer which is nil
+		}
+		if field.omitEmpty && value.IsZero() {
+			continue
+		}
+		property, err := marshalProperty(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal property %q: %w", field.name, err)
+		}
+		properties[field.name] = property
+	}
+	return properties, nil
+}
+
+// UnmarshalProperties sets the fields of the struct v points to from custom contact properties, e.g. from
+// Contact.Properties. Fields are mapped the same way as by MarshalProperties. Fields whose property is not included
+// are left unchanged, and fields whose property is nil are set to their zero value.
+//
+// Values that don't match the type of their field are rejected with a PropertyMismatchError. Numbers must fit into
+// their field without losing precision, and date fields accept the values Properties.Time does.
+func UnmarshalProperties(properties map[string]any, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("properties must be unmarshaled into a non-nil pointer to a struct, got %T", v)
+	}
+	rv = rv.Elem()
+
+	fields, err := propertyFields(rv.Type())
+	if err != nil {
+		return err
+	}
+	for _, field := range fields {
+		value, ok := properties[field.name]
+		if !ok {
+			continue
+		}
+		if err := unmarshalProperty(field.name, value, fieldByIndexAlloc(rv, field.index)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// propertyField is a struct field mapped to a custom contact property.
+type propertyField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// propertyFields returns the fields of the given struct type that are mapped to custom contact properties.
+func propertyFields(t reflect.Type) ([]propertyField, error) {
+	var fields []propertyField
+	names := make(map[string]string)
+	for _, field := range reflect.VisibleFields(t) {
+		tag, ok := field.Tag.Lookup("loops")
+		if !ok || tag == "-" || !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			return nil, fmt.Errorf("missing property name in tag of field %s", field.Name)
+		}
+		if other, ok := names[name]; ok {
+			return nil, fmt.Errorf("fields %s and %s are both mapped to property %q", other, field.Name, name)
+		}
+		names[name] = field.Name
+		fields = append(fields, propertyField{name: name, index: field.Index, omitEmpty: options == "omitempty"})
+	}
+	return fields, nil
+}
+
+// fieldByIndexAlloc returns the nested field of v with the given index, allocating nil embedded struct pointers.
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// marshalProperty converts a struct field value to a custom contact property value.
+func marshalProperty(v reflect.Value) (any, error) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, nil
+		}
+		return marshalProperty(v.Elem())
+	}
+	if v.Type() == timeType {
+		return v.Interface(), nil
+	}
+	if marshaler, ok := asInterface[PropertyMarshaler](v); ok {
+		return marshaler.MarshalProperty()
+	}
+	if marshaler, ok := asInterface[encoding.TextMarshaler](v); ok {
+		text, err := marshaler.MarshalText()
+		return string(text), err
+	}
+
+	switch v.Kind() { //nolint:exhaustive // all other kinds are unsupported
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint(), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", v.Type())
+	}
+}
+
+// unmarshalProperty sets the struct field v from the custom contact property value with the given name.
+func unmarshalProperty(name string, value any, v reflect.Value) error {
+	if value == nil {
+		v.SetZero()
+		return nil
+	}
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return unmarshalProperty(name, value, v.Elem())
+	}
+
+	property := Properties{name: value}
+	if v.Type() == timeType {
+		t, err := property.Time(name)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if unmarshaler, ok := asInterface[PropertyUnmarshaler](v); ok {
+		return unmarshaler.UnmarshalProperty(value)
+	}
+	if unmarshaler, ok := asInterface[encoding.TextUnmarshaler](v); ok {
+		s, err := property.String(name)
+		if err != nil {
+			return err
+		}
+		return unmarshaler.UnmarshalText([]byte(s))
+	}
+
+	mismatch := &PropertyMismatchError{Key: name, Expected: v.Type().String(), Value: value}
+	switch v.Kind() { //nolint:exhaustive // all other kinds are unsupported
+	case reflect.String:
+		s, err := property.String(name)
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := property.Bool(name)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := property.Int64(name)
+		if err != nil || v.OverflowInt(n) {
+			return mismatch
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := property.Int64(name)
+		if err != nil || n < 0 || v.OverflowUint(uint64(n)) { //nolint:gosec // n is not negative
+			return mismatch
+		}
+		v.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		f, err := property.Float64(name)
+		if err != nil || v.OverflowFloat(f) {
+			return mismatch
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("failed to unmarshal property %q: unsupported type %s", name, v.Type())
+	}
+	return nil
+}
+
+// asInterface returns v as T, if v or a pointer to it implements T.
+func asInterface[T any](v reflect.Value) (T, bool) {
+	if v.CanAddr() {
+		if t, ok := v.Addr().Interface().(T); ok {
+			return t, true
+		}
+	}
+	t, ok := v.Interface().(T)
+	return t, ok
+}
@@ -0,0 +1,147 @@
+package loops
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// plan is a custom property type implementing PropertyMarshaler and PropertyUnmarshaler.
+type plan struct {
+	tier string
+}
+
+func (p plan) MarshalProperty() (any, error) {
+	return strings.ToLower(p.tier), nil
+}
+
+func (p *plan) UnmarshalProperty(value any) error {
+	s, ok := value.(string)
+	if !ok {
+		return errors.New("plan must be a string")
+	}
+	p.tier = strings.ToUpper(s)
+	return nil
+}
+
+type billing struct {
+	Seats uint8 `loops:"seats"`
+}
+
+type accountProperties struct {
+	billing
+	Role       string     `loops:"role"`
+	Missions   int        `loops:"missions"`
+	Hours      float64    `loops:"hoursInSpace"`
+	MoonWalker bool       `loops:"moonWalker"`
+	LaunchedAt time.Time  `loops:"launchedAt"`
+	Nickname   *string    `loops:"nickname"`
+	Plan       plan       `loops:"plan"`
+	IP         netip.Addr `loops:"ip,omitempty"`
+	Company    string     `loops:"company,omitempty"`
+	Internal   string     `loops:"-"`
+	Untagged   string
+}
+
+func TestMarshalProperties(t *testing.T) {
+	launch := time.Date(1969, 7, 16, 13, 32, 0, 0, time.UTC)
+	properties, err := MarshalProperties(&accountProperties{
+		billing:    billing{Seats: 3},
+		Role:       "Commander",
+		Missions:   2,
+		Hours:      206.2,
+		MoonWalker: true,
+		LaunchedAt: launch,
+		Plan:       plan{tier: "PRO"},
+		IP:         netip.MustParseAddr("10.0.0.1"),
+		Internal:   "secret",
+		Untagged:   "ignored",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"seats":        uint64(3),
+		"role":         "Commander",
+		"missions":     int64(2),
+		"hoursInSpace": 206.2,
+		"moonWalker":   true,
+		"launchedAt":   launch,
+		"nickname":     nil,
+		"plan":         "pro",
+		"ip":           "10.0.0.1",
+	}, properties)
+
+	_, err = MarshalProperties(map[string]any{})
+	require.Error(t, err)
+	_, err = MarshalProperties(struct {
+		Tags []string `loops:"tags"`
+	}{Tags: []string{"a"}})
+	require.EqualError(t, err, `failed to marshal property "tags": unsupported type []string`)
+	_, err = MarshalProperties(struct {
+		A string `loops:"name"`
+		B string `loops:"name"`
+	}{})
+	require.EqualError(t, err, `fields A and B are both mapped to property "name"`)
+}
+
+func TestUnmarshalProperties(t *testing.T) {
+	properties := Properties{
+		"seats":        float64(3),
+		"role":         "Commander",
+		"missions":     float64(2),
+		"hoursInSpace": 206.2,
+		"moonWalker":   true,
+		"launchedAt":   float64(-14552880000),
+		"nickname":     "Neil",
+		"plan":         "pro",
+		"ip":           "10.0.0.1",
+		"company":      nil,
+		"unknown":      "ignored",
+	}
+	account := accountProperties{Company: "NASA", Untagged: "unchanged"}
+	require.NoError(t, UnmarshalProperties(properties, &account))
+	assert.Equal(t, accountProperties{
+		billing:    billing{Seats: 3},
+		Role:       "Commander",
+		Missions:   2,
+		Hours:      206.2,
+		MoonWalker: true,
+		LaunchedAt: time.Date(1969, 7, 16, 13, 32, 0, 0, time.UTC),
+		Nickname:   String("Neil"),
+		Plan:       plan{tier: "PRO"},
+		IP:         netip.MustParseAddr("10.0.0.1"),
+		Untagged:   "unchanged",
+	}, account)
+
+	roundTrip, err := MarshalProperties(&account)
+	require.NoError(t, err)
+	var again accountProperties
+	require.NoError(t, UnmarshalProperties(roundTrip, &again))
+	again.Untagged = account.Untagged
+	assert.Equal(t, account, again)
+}
+
+func TestUnmarshalPropertiesMismatch(t *testing.T) {
+	var account accountProperties
+	for _, properties := range []Properties{
+		{"role": 5.0},
+		{"missions": "two"},
+		{"missions": 2.5},
+		{"seats": 300.0},
+		{"seats": -1.0},
+		{"moonWalker": "yes"},
+		{"launchedAt": "next tuesday"},
+	} {
+		err := UnmarshalProperties(properties, &account)
+		require.ErrorIs(t, err, ErrPropertyMismatch, properties)
+	}
+
+	err := UnmarshalProperties(Properties{"seats": 300.0}, &account)
+	require.EqualError(t, err, `custom property "seats" is not a valid uint8: 300 (float64)`)
+	require.EqualError(t, UnmarshalProperties(Properties{"plan": 1.0}, &account), "plan must be a string")
+	require.Error(t, UnmarshalProperties(Properties{}, account), "must be a pointer")
+}
@@ -0,0 +1,297 @@
+package loops
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrQuotaExceeded is returned (wrapped in a QuotaExceededError) for sends exceeding a local quota.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// QuotaScope selects what a quota is keyed by.
+type QuotaScope string
+
+const (
+	// QuotaScopeTransactionalID limits SendTransactionalEmail calls per transactional ID.
+	QuotaScopeTransactionalID QuotaScope = "transactionalId"
+	// QuotaScopeEventName limits SendEvent calls per event name.
+	QuotaScopeEventName QuotaScope = "eventName"
+)
+
+// Quota limits the number of sends for a transactional email or an event within a fixed time window, e.g.
+// at most 1000 password reset emails per hour.
+type Quota struct {
+	// What the quota is keyed by.
+	Scope QuotaScope `json:"scope" yaml:"scope"`
+	// The transactional ID or event name the quota applies to. If empty, the quota applies to every transactional ID
+	// or event name separately.
+	Key string `json:"key,omitempty" yaml:"key,omitempty"`
+	// The maximum number of sends within a window.
+	Limit int64 `json:"limit" yaml:"limit"`
+	// The length of a window, e.g. time.Minute, time.Hour or 24*time.Hour. Windows are aligned to the Unix epoch.
+	Window time.Duration `json:"window" yaml:"window"`
+}
+
+func (q Quota) validate() error {
+	if q.Scope != QuotaScopeTransactionalID && q.Scope != QuotaScopeEventName {
+		return fmt.Errorf("invalid quota scope: %q", q.Scope)
+	}
+	if q.Limit <= 0 || q.Window <= 0 {
+		return fmt.Errorf("invalid quota for %s %q: limit and window must be positive", q.Scope, q.Key)
+	}
+	return nil
+}
+
+// QuotaExceededError is returned for sends exceeding a local quota.
+type QuotaExceededError struct {
+	// The quota that was exceeded.
+	Quota Quota
+	// The transactional ID or event name of the rejected send.
+	Key string
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("quota exceeded: more than %d sends for %s %q within %s", e.Quota.Limit, e.Quota.Scope, e.Key,
+		e.Quota.Window)
+}
+
+func (e *QuotaExceededError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// QuotaAlert is passed to the alert hook when a quota crosses its alert threshold, or is exceeded.
+type QuotaAlert struct {
+	// The quota that crossed its threshold.
+	Quota Quota
+	// The transactional ID or event name of the send that crossed the threshold.
+	Key string
+	// The number of sends within the current window, including the one that crossed the threshold.
+	Count int64
+	// Whether the quota was exceeded, or only the alert threshold was crossed.
+	Exceeded bool
+}
+
+// QuotaAlertHook is called when a send reaches the alert threshold of a quota, and when a send is the first one to
+// exceed a quota within its window.
+type QuotaAlertHook func(ctx context.Context, alert QuotaAlert)
+
+// CounterStore stores the counters used to enforce quotas. Implementations must be safe for concurrent use.
+type CounterStore interface {
+	// Increment adds delta to the counter with the given key and returns its new value. Counters that don't exist yet
+	// start at 0. A counter may be discarded once expiresAt has passed.
+	Increment(ctx context.Context, key string, delta int64, expiresAt time.Time) (int64, error)
+}
+
+// WithQuotas enforces the given quotas client-side before SendTransactionalEmail and SendEvent, failing sends that
+// exceed them with ErrQuotaExceeded. Counters are kept in the given store.
+func WithQuotas(store CounterStore, quotas ...Quota) ClientOption {
+	return func(c *clientConfig) {
+		c.quotaStore = store
+		c.quotas = append(c.quotas, quotas...)
+	}
+}
+
+// WithQuotaAlert registers a hook that is called once a quota reaches the given fraction of its limit
+// (e.g. 0.8 for 80%), and once it is exceeded.
+func WithQuotaAlert(threshold float64, hook QuotaAlertHook) ClientOption {
+	return func(c *clientConfig) {
+		c.quotaAlertThreshold = threshold
+		c.quotaAlertHook = hook
+	}
+}
+
+// quotaEnforcer enforces quotas for sends of a client.
+type quotaEnforcer struct {
+	quotas         []Quota
+	store          CounterStore
+	alertThreshold float64
+	alertHook      QuotaAlertHook
+	now            func() time.Time
+}
+
+func newQuotaEnforcer(config *clientConfig) (*quotaEnforcer, error) {
+	if len(config.quotas) == 0 {
+		return nil, nil //nolint:nilnil // no quotas configured, which is not an error
+	}
+	if config.quotaStore == nil {
+		return nil, errors.New("quotas require a counter store")
+	}
+	for _, q := range config.quotas {
+		if err := q.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if config.quotaAlertThreshold < 0 || config.quotaAlertThreshold > 1 {
+		return nil, errors.New("invalid quota alert threshold: must be between 0 and 1")
+	}
+	return &quotaEnforcer{
+		quotas:         config.quotas,
+		store:          config.quotaStore,
+		alertThreshold: config.quotaAlertThreshold,
+		alertHook:      config.quotaAlertHook,
+		now:            time.Now,
+	}, nil
+}
+
+// check counts a send against all matching quotas, and returns a QuotaExceededError if one of them is exceeded.
+// A rejected send is only counted against the quota it exceeded. Payloads other than sends are ignored.
+func (q *quotaEnforcer) check(ctx context.Context, payload any) error {
+	var scope QuotaScope
+	var key string
+	switch p := payload.(type) {
+	case *TransactionalEmail:
+		scope, key = QuotaScopeTransactionalID, p.TransactionalID
+	case *Event:
+		scope, key = QuotaScopeEventName, p.EventName
+	default:
+		return nil
+	}
+
+	now := q.now()
+	type increment struct {
+		counterKey string
+		expiresAt  time.Time
+	}
+	var counted []increment
+	rollback := func() {
+		for _, inc := range counted {
+			_, _ = q.store.Increment(context.WithoutCancel(ctx), inc.counterKey, -1, inc.expiresAt)
+		}
+	}
+
+	for _, quota := range q.quotas {
+		if quota.Scope != scope || (quota.Key != "" && quota.Key != key) {
+			continue
+		}
+		windowStart := now.Truncate(quota.Window)
+		expiresAt := windowStart.Add(quota.Window)
+		counterKey := fmt.Sprintf("%s|%s|%s|%s|%d", scope, quota.Key, key, quota.Window, windowStart.UnixNano())
+		count, err := q.store.Increment(ctx, counterKey, 1, expiresAt)
+		if err != nil {
+			rollback()
+			return fmt.Errorf("failed to count send against quota: %w", err)
+		}
+
+		if count > quota.Limit {
+			rollback()
+			if count == quota.Limit+1 {
+				q.alert(ctx, QuotaAlert{Quota: quota, Key: key, Count: count, Exceeded: true})
+			}
+			return &QuotaExceededError{Quota: quota, Key: key}
+		}
+		counted = append(counted, increment{counterKey: counterKey, expiresAt: expiresAt})
+		if q.alertThreshold > 0 && count == int64(math.Ceil(q.alertThreshold*float64(quota.Limit))) {
+			q.alert(ctx, QuotaAlert{Quota: quota, Key: key, Count: count})
+		}
+	}
+	return nil
+}
+
+func (q *quotaEnforcer) alert(ctx context.Context, alert QuotaAlert) {
+	if q.alertHook != nil {
+		q.alertHook(ctx, alert)
+	}
+}
+
+type counter struct {
+	Value     int64     `json:"value"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// MemoryCounterStore is a CounterStore keeping counters in memory.
+type MemoryCounterStore struct {
+	mu       sync.Mutex
+	counters map[string]counter
+}
+
+// NewMemoryCounterStore creates a new, empty in-memory counter store.
+func NewMemoryCounterStore() *MemoryCounterStore {
+	return &MemoryCounterStore{counters: make(map[string]counter)}
+}
+
+func (s *MemoryCounterStore) Increment(_ context.Context, key string, delta int64, expiresAt time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return incrementCounter(s.counters, key, delta, expiresAt, time.Now()), nil
+}
+
+// FileCounterStore is a CounterStore persisting counters to a JSON file, so they survive restarts.
+// It is meant for a single process, the file must not be shared between processes.
+type FileCounterStore struct {
+	path string
+
+	mu       sync.Mutex
+	counters map[string]counter
+}
+
+// NewFileCounterStore creates a counter store persisted to the file at the given path, loading existing counters
+// from it if it exists.
+func NewFileCounterStore(path string) (*FileCounterStore, error) {
+	s := &FileCounterStore{path: path, counters: make(map[string]counter)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read counter file: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.counters); err != nil {
+		return nil, fmt.Errorf("invalid counter file: %w", err)
+	}
+	return s, nil
+}
+
+func (s *FileCounterStore) Increment(_ context.Context, key string, delta int64, expiresAt time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value := incrementCounter(s.counters, key, delta, expiresAt, time.Now())
+	if err := s.save(); err != nil {
+		return 0, err
+	}
+	return value, nil
+}
+
+// save atomically replaces the counter file with the current counters.
+func (s *FileCounterStore) save() error {
+	data, err := json.Marshal(s.counters)
+	if err != nil {
+		return fmt.Errorf("failed to marshal counters: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write counter file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write counter file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write counter file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write counter file: %w", err)
+	}
+	return nil
+}
+
+// incrementCounter adds delta to the counter with the given key, pruning expired counters along the way.
+func incrementCounter(counters map[string]counter, key string, delta int64, expiresAt, now time.Time) int64 {
+	for k, c := range counters {
+		if now.After(c.ExpiresAt) {
+			delete(counters, k)
+		}
+	}
+	c := counters[key]
+	c.Value += delta
+	c.ExpiresAt = expiresAt
+	counters[key] = c
+	return c.Value
+}
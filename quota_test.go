@@ -0,0 +1,138 @@
+package loops
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuotaPerTransactionalID(t *testing.T) {
+	httpClient := &recordingHTTPClient{}
+	var alerts []QuotaAlert
+	client, err := NewClient(WithHTTPClient(httpClient),
+		WithQuotas(NewMemoryCounterStore(), Quota{
+			Scope:  QuotaScopeTransactionalID,
+			Key:    "password-reset",
+			Limit:  4,
+			Window: time.Hour,
+		}),
+		WithQuotaAlert(0.5, func(_ context.Context, alert QuotaAlert) {
+			alerts = append(alerts, alert)
+		}),
+	)
+	require.NoError(t, err)
+
+	send := func(transactionalID string) error {
+		return client.SendTransactionalEmail(context.Background(), &TransactionalEmail{
+			TransactionalID: transactionalID,
+			Email:           "test@example.com",
+		})
+	}
+
+	for range 4 {
+		require.NoError(t, send("password-reset"))
+	}
+	for range 3 {
+		err = send("password-reset")
+		require.ErrorIs(t, err, ErrQuotaExceeded)
+		var quotaErr *QuotaExceededError
+		require.ErrorAs(t, err, &quotaErr)
+		assert.Equal(t, "password-reset", quotaErr.Key)
+	}
+	// other transactional emails are not affected
+	require.NoError(t, send("welcome"))
+	assert.Len(t, httpClient.bodies, 5)
+
+	require.Len(t, alerts, 2)
+	assert.False(t, alerts[0].Exceeded)
+	assert.Equal(t, int64(2), alerts[0].Count)
+	assert.True(t, alerts[1].Exceeded)
+	assert.Equal(t, int64(5), alerts[1].Count)
+}
+
+func TestQuotaPerEventNameWindow(t *testing.T) {
+	httpClient := &recordingHTTPClient{}
+	client, err := NewClient(WithHTTPClient(httpClient),
+		WithQuotas(NewMemoryCounterStore(), Quota{Scope: QuotaScopeEventName, Limit: 1, Window: time.Minute}),
+	)
+	require.NoError(t, err)
+	now := time.Now().Truncate(time.Hour).Add(time.Hour)
+	client.quotas.now = func() time.Time { return now }
+
+	send := func(eventName string) error {
+		return client.SendEvent(context.Background(), &Event{Email: String("test@example.com"), EventName: eventName})
+	}
+
+	require.NoError(t, send("signup"))
+	require.ErrorIs(t, send("signup"), ErrQuotaExceeded)
+	// an empty key applies the quota to every event name separately
+	require.NoError(t, send("login"))
+
+	// a new window starts
+	now = now.Add(time.Minute)
+	require.NoError(t, send("signup"))
+}
+
+func TestQuotaRejectedSendNotCountedAgainstOtherQuotas(t *testing.T) {
+	client, err := NewClient(WithHTTPClient(&recordingHTTPClient{}),
+		WithQuotas(NewMemoryCounterStore(),
+			Quota{Scope: QuotaScopeEventName, Key: "signup", Limit: 2, Window: time.Hour},
+			Quota{Scope: QuotaScopeEventName, Key: "signup", Limit: 1, Window: time.Minute},
+		),
+	)
+	require.NoError(t, err)
+	now := time.Now().Truncate(time.Hour).Add(time.Hour)
+	client.quotas.now = func() time.Time { return now }
+	event := &Event{Email: String("test@example.com"), EventName: "signup"}
+
+	require.NoError(t, client.SendEvent(context.Background(), event))
+	// rejected by the per minute quota, so it must not count against the hourly one
+	require.ErrorIs(t, client.SendEvent(context.Background(), event), ErrQuotaExceeded)
+
+	now = now.Add(time.Minute)
+	require.NoError(t, client.SendEvent(context.Background(), event))
+	now = now.Add(time.Minute)
+	err = client.SendEvent(context.Background(), event)
+	var quotaErr *QuotaExceededError
+	require.ErrorAs(t, err, &quotaErr)
+	assert.Equal(t, time.Hour, quotaErr.Quota.Window)
+}
+
+func TestFileCounterStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counters.json")
+	expiresAt := time.Now().Add(time.Hour)
+
+	store, err := NewFileCounterStore(path)
+	require.NoError(t, err)
+	value, err := store.Increment(context.Background(), "key", 2, expiresAt)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), value)
+
+	// counters survive a restart
+	store, err = NewFileCounterStore(path)
+	require.NoError(t, err)
+	value, err = store.Increment(context.Background(), "key", 1, expiresAt)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), value)
+
+	// expired counters are discarded
+	value, err = store.Increment(context.Background(), "expired", 1, time.Now().Add(-time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), value)
+	value, err = store.Increment(context.Background(), "expired", 1, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), value)
+}
+
+func TestQuotaInvalid(t *testing.T) {
+	_, err := NewClient(WithQuotas(nil, Quota{Scope: QuotaScopeEventName, Limit: 1, Window: time.Minute}))
+	require.Error(t, err)
+	_, err = NewClient(WithQuotas(NewMemoryCounterStore(), Quota{Scope: QuotaScopeEventName, Window: time.Minute}))
+	require.Error(t, err)
+	_, err = NewClient(WithQuotas(NewMemoryCounterStore(), Quota{Scope: "userId", Limit: 1, Window: time.Minute}))
+	require.Error(t, err)
+}
@@ -0,0 +1,76 @@
+package loops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Do sends a request to an endpoint of the Loops API that has no dedicated method in this client yet, such as
+// a newly released one. The path is relative to the API URL (e.g. "/contacts/suppression"), and may contain a query
+// string. Paths that are empty or absolute URLs are rejected, so requests never leave the API URL. A non-nil body
+// is marshaled using the client's codec, and a successful response is unmarshaled into out, unless out is nil or the
+// response has no body (e.g. 204 No Content).
+//
+// The request goes through the same machinery as all other calls: request interceptors and authentication,
+// error mapping, concurrency limits, hedging, kill switches and the client's lifecycle. GET requests are identified
+// as OperationRawRead, all other requests as OperationRawWrite, e.g. for disabling them using a kill switch.
+// Read-only clients only allow GET requests.
+func (c *Client) Do(ctx context.Context, method, path string, body, out any) error {
+	op := OperationRawWrite
+	if method == http.MethodGet {
+		op = OperationRawRead
+	}
+	err := c.do(ctx, op, method, path, body, out)
+	c.stats.record(op, err)
+	return err
+}
+
+// do implements Do, without recording the call in the client's stats.
+func (c *Client) do(ctx context.Context, op Operation, method, path string, body, out any) error {
+	if err := c.lifecycle.checkOpen(); err != nil {
+		return err
+	}
+	if err := checkRelativePath(path); err != nil {
+		return err
+	}
+	if ok, err := c.allow(ctx, op, body); !ok {
+		return err
+	}
+
+	req, err := newRequestWithBody(c, ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	return c.sendRequestInto(req, out, true)
+}
+
+// checkRelativePath rejects paths that are empty or would replace the scheme or host of the API URL, so the API key
+// is never sent anywhere else.
+func checkRelativePath(path string) error {
+	if path == "" {
+		return errors.New("path must not be empty")
+	}
+	u, err := url.Parse(path)
+	if err != nil {
+		return fmt.Errorf("invalid path: %w", err)
+	}
+	if u.IsAbs() || u.Host != "" {
+		return fmt.Errorf("invalid path %q: must be relative to the API URL", path)
+	}
+	return nil
+}
+
+// Do is a generic wrapper around Client.Do, returning the unmarshaled response, e.g.
+//
+//	suppressed, err := loops.Do[*Suppression](ctx, client, http.MethodGet, "/contacts/suppression?email=...", nil)
+func Do[T any](ctx context.Context, c *Client, method, path string, body any) (T, error) {
+	var response T
+	if err := c.Do(ctx, method, path, body, &response); err != nil {
+		var none T
+		return none, err
+	}
+	return response, nil
+}
@@ -0,0 +1,109 @@
+package loops
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type suppression struct {
+	Email      string `json:"email"`
+	Suppressed bool   `json:"suppressed"`
+}
+
+func TestDo(t *testing.T) {
+	var gotReq *http.Request
+	var gotBody string
+	httpClient := httpClientFunc(func(req *http.Request) (*http.Response, error) {
+		gotReq = req
+		if req.Body != nil {
+			b, err := io.ReadAll(req.Body)
+			if err != nil {
+				return nil, err
+			}
+			gotBody = string(b)
+		}
+		return jsonResponse(http.StatusOK, `{"email":"test@example.com","suppressed":true}`), nil
+	})
+	client, err := NewClient(WithHTTPClient(httpClient), WithAPIKey("secret"))
+	require.NoError(t, err)
+
+	out := &suppression{}
+	err = client.Do(context.Background(), http.MethodPost, "/contacts/suppression", map[string]string{"email": "test@example.com"}, out)
+	require.NoError(t, err)
+	assert.Equal(t, "https://app.loops.so/api/v1/contacts/suppression", gotReq.URL.String())
+	assert.Equal(t, "Bearer secret", gotReq.Header.Get("Authorization"))
+	assert.JSONEq(t, `{"email":"test@example.com"}`, gotBody)
+	assert.Equal(t, &suppression{Email: "test@example.com", Suppressed: true}, out)
+}
+
+func TestDoGeneric(t *testing.T) {
+	var gotReq *http.Request
+	httpClient := httpClientFunc(func(req *http.Request) (*http.Response, error) {
+		gotReq = req
+		return jsonResponse(http.StatusOK, `{"email":"test@example.com","suppressed":false}`), nil
+	})
+	client, err := NewClient(WithHTTPClient(httpClient))
+	require.NoError(t, err)
+
+	out, err := Do[*suppression](context.Background(), client, http.MethodGet, "/contacts/suppression?email=test%40example.com", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "test@example.com", gotReq.URL.Query().Get("email"))
+	assert.Nil(t, gotReq.Body)
+	assert.Equal(t, &suppression{Email: "test@example.com"}, out)
+}
+
+func TestDoErrorResponse(t *testing.T) {
+	httpClient := httpClientFunc(func(*http.Request) (*http.Response, error) {
+		return jsonResponse(http.StatusBadRequest, `{"success":false,"message":"Invalid email"}`), nil
+	})
+	client, err := NewClient(WithHTTPClient(httpClient))
+	require.NoError(t, err)
+
+	err = client.Do(context.Background(), http.MethodDelete, "/contacts/suppression", nil, nil)
+	require.EqualError(t, err, "Invalid email")
+}
+
+func TestEmptyResponseBody(t *testing.T) {
+	httpClient := httpClientFunc(func(*http.Request) (*http.Response, error) {
+		return jsonResponse(http.StatusOK, ""), nil
+	})
+	client, err := NewClient(WithHTTPClient(httpClient))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// typed operations need a response body
+	_, err = client.CreateContact(ctx, &Contact{Email: "test@example.com"})
+	require.ErrorContains(t, err, "failed to unmarshal response body")
+	_, err = client.UpdateContactFields(ctx, &ContactUpdate{Email: "test@example.com"})
+	require.ErrorContains(t, err, "failed to unmarshal response body")
+
+	out := &suppression{Email: "unchanged"}
+	require.NoError(t, client.Do(ctx, http.MethodDelete, "/contacts/suppression", nil, out))
+	assert.Equal(t, &suppression{Email: "unchanged"}, out)
+}
+
+func TestDoGuards(t *testing.T) {
+	httpClient := &recordingHTTPClient{}
+	killSwitches := NewKillSwitches()
+	client, err := NewClient(WithHTTPClient(httpClient), WithReadOnly(), WithKillSwitches(killSwitches))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	err = client.Do(ctx, http.MethodPost, "/contacts/suppression", nil, nil)
+	require.ErrorIs(t, err, ErrReadOnly)
+	require.NoError(t, client.Do(ctx, http.MethodGet, "/contacts/suppression", nil, nil))
+
+	for _, path := range []string{"", "https://other.example.com/collect", "//other.example.com/collect"} {
+		require.Error(t, client.Do(ctx, http.MethodGet, path, nil, nil), path)
+	}
+
+	killSwitches.Disable(OperationRawRead)
+	err = client.Do(ctx, http.MethodGet, "/contacts/suppression", nil, nil)
+	require.ErrorIs(t, err, ErrOperationDisabled)
+	assert.Len(t, httpClient.bodies, 1)
+}
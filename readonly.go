@@ -0,0 +1,82 @@
+package loops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrReadOnly is returned for calls of mutating operations on a read-only client.
+var ErrReadOnly = errors.New("client is read-only")
+
+// WithReadOnly makes the client read-only, rejecting all mutating operations (such as creating contacts or sending
+// emails) with ErrReadOnly before any request is built.
+func WithReadOnly() ClientOption {
+	return func(c *clientConfig) {
+		c.readOnly = true
+	}
+}
+
+// ReadOnlyClient only exposes the read operations of a Client, so mutating operations are rejected at compile time.
+type ReadOnlyClient struct {
+	client *Client
+}
+
+// NewReadOnlyClient creates a new read-only Loops client. The underlying client is created with WithReadOnly,
+// so mutating operations are rejected at runtime as well, e.g. for requests sent using Client.Do.
+func NewReadOnlyClient(opts ...ClientOption) (*ReadOnlyClient, error) {
+	client, err := NewClient(append(opts, WithReadOnly())...)
+	if err != nil {
+		return nil, err
+	}
+	return &ReadOnlyClient{client: client}, nil
+}
+
+// ReadOnly returns a view of the client that only exposes its read operations, e.g. to hand to tooling that must
+// never modify contacts or send emails.
+func (c *Client) ReadOnly() *ReadOnlyClient {
+	return &ReadOnlyClient{client: c}
+}
+
+// FindContact finds a contact by email or userId. See Client.FindContact.
+func (c *ReadOnlyClient) FindContact(ctx context.Context, contact *ContactIdentifier) (*Contact, error) {
+	return c.client.FindContact(ctx, contact)
+}
+
+// GetMailingLists retrieves a list of an account's mailing lists. See Client.GetMailingLists.
+func (c *ReadOnlyClient) GetMailingLists(ctx context.Context) ([]*MailingList, error) {
+	return c.client.GetMailingLists(ctx)
+}
+
+// GetContactProperties retrieves a list of an account's contact properties. See Client.GetContactProperties.
+func (c *ReadOnlyClient) GetContactProperties(ctx context.Context, opts ContactPropertyListOptions) ([]*ContactProperty, error) {
+	return c.client.GetContactProperties(ctx, opts)
+}
+
+// ListTransactionalEmails retrieves a list of published transactional emails. See Client.ListTransactionalEmails.
+func (c *ReadOnlyClient) ListTransactionalEmails(ctx context.Context, opts ListTransactionalEmailsOptions) (*TransactionalEmailList, error) {
+	return c.client.ListTransactionalEmails(ctx, opts)
+}
+
+// GetDedicatedSendingIPs retrieves a list of Loops' dedicated sending IP addresses. See Client.GetDedicatedSendingIPs.
+func (c *ReadOnlyClient) GetDedicatedSendingIPs(ctx context.Context) ([]string, error) {
+	return c.client.GetDedicatedSendingIPs(ctx)
+}
+
+// TestAPIKey tests that an API key is valid. See Client.TestAPIKey.
+func (c *ReadOnlyClient) TestAPIKey(ctx context.Context) (*APIKeyInfo, error) {
+	return c.client.TestAPIKey(ctx)
+}
+
+// Close shuts down the underlying client. See Client.Close.
+func (c *ReadOnlyClient) Close(ctx context.Context) error {
+	return c.client.Close(ctx)
+}
+
+// checkReadOnly returns ErrReadOnly if the client is read-only and op is a mutating operation.
+func (c *Client) checkReadOnly(op Operation) error {
+	if c.readOnly && !isReadOperation(op) {
+		return fmt.Errorf("%w: operation %s is not allowed", ErrReadOnly, op)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package loops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadOnlyRejectsMutatingOperations(t *testing.T) {
+	httpClient := &recordingHTTPClient{}
+	client, err := NewClient(WithHTTPClient(httpClient), WithReadOnly())
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	_, err = client.CreateContact(ctx, &Contact{Email: "test@example.com"})
+	require.ErrorIs(t, err, ErrReadOnly)
+	_, err = client.UpdateContact(ctx, &Contact{Email: "test@example.com"})
+	require.ErrorIs(t, err, ErrReadOnly)
+	err = client.DeleteContact(ctx, &ContactIdentifier{Email: String("test@example.com")})
+	require.ErrorIs(t, err, ErrReadOnly)
+	err = client.SendEvent(ctx, &Event{Email: String("test@example.com"), EventName: "signup"})
+	require.ErrorIs(t, err, ErrReadOnly)
+	err = client.SendTransactionalEmail(ctx, &TransactionalEmail{TransactionalID: "tx", Email: "test@example.com"})
+	require.ErrorIs(t, err, ErrReadOnly)
+	err = client.CreateContactProperty(ctx, &ContactPropertyCreate{Name: "favoriteColor", Type: PropertyTypeString})
+	require.ErrorIs(t, err, ErrReadOnly)
+	assert.Empty(t, httpClient.bodies, "no request must be sent")
+}
+
+func TestReadOnlyAllowsReads(t *testing.T) {
+	httpClient := &recordingHTTPClient{}
+	client, err := NewReadOnlyClient(WithHTTPClient(httpClient))
+	require.NoError(t, err)
+
+	_, err = client.GetDedicatedSendingIPs(context.Background())
+	require.NotErrorIs(t, err, ErrReadOnly)
+	assert.Len(t, httpClient.bodies, 1)
+}
+
+func TestReadOnlyView(t *testing.T) {
+	httpClient := &recordingHTTPClient{}
+	client, err := NewClient(WithHTTPClient(httpClient))
+	require.NoError(t, err)
+
+	_, err = client.ReadOnly().GetMailingLists(context.Background())
+	require.NotErrorIs(t, err, ErrReadOnly)
+	assert.Len(t, httpClient.bodies, 1)
+}
@@ -0,0 +1,258 @@
+package loops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrRecipientLimited is returned (wrapped in a RecipientLimitedError) for sends exceeding a per-recipient limit.
+var ErrRecipientLimited = errors.New("recipient send limit exceeded")
+
+// RecipientLimitedError is returned for sends exceeding a per-recipient limit.
+type RecipientLimitedError struct {
+	// The recipient, in the form "email:<email>" or "userId:<userId>".
+	Recipient string
+	// The transactional ID or event name of the rejected send.
+	Key string
+	// The time until the recipient's window allows another send.
+	RetryAfter time.Duration
+}
+
+func (e *RecipientLimitedError) Error() string {
+	return fmt.Sprintf("too many sends to %s (%s), retry after %s", e.Recipient, e.Key, e.RetryAfter)
+}
+
+func (e *RecipientLimitedError) Is(target error) bool {
+	return target == ErrRecipientLimited
+}
+
+// RecipientAction determines what happens with sends exceeding a per-recipient limit.
+type RecipientAction int
+
+const (
+	// RecipientActionError fails the send with ErrRecipientLimited.
+	RecipientActionError RecipientAction = iota
+	// RecipientActionDrop silently drops the send, the call returns without error.
+	RecipientActionDrop
+	// RecipientActionDelay waits until the send is within the limit again, up to RecipientPolicy.MaxDelay.
+	RecipientActionDelay
+)
+
+var recipientActionNames = []string{"error", "drop", "delay"}
+
+func (a RecipientAction) String() string {
+	if a < RecipientActionError || a > RecipientActionDelay {
+		return fmt.Sprintf("RecipientAction(%d)", int(a))
+	}
+	return recipientActionNames[a]
+}
+
+// MarshalText encodes the action as "error", "drop" or "delay".
+func (a RecipientAction) MarshalText() ([]byte, error) {
+	if a < RecipientActionError || a > RecipientActionDelay {
+		return nil, fmt.Errorf("invalid recipient action: %d", a)
+	}
+	return []byte(a.String()), nil
+}
+
+// UnmarshalText decodes an action encoded as "error", "drop" or "delay".
+func (a *RecipientAction) UnmarshalText(text []byte) error {
+	i := slices.Index(recipientActionNames, string(text))
+	if i < 0 {
+		return fmt.Errorf("invalid recipient action: %q", text)
+	}
+	*a = RecipientAction(i)
+	return nil
+}
+
+// RecipientLimit caps the number of sends to a single recipient within a sliding window. A zero limit means no limit.
+type RecipientLimit struct {
+	Limit  int           `json:"limit" yaml:"limit"`
+	Window time.Duration `json:"window" yaml:"window"`
+}
+
+// RecipientPolicy protects single recipients against send storms, by capping the number of transactional emails
+// and events per recipient email or userId.
+type RecipientPolicy struct {
+	// Limit for transactional emails to a recipient, shared by all transactional IDs without an override.
+	TransactionalEmails RecipientLimit `json:"transactionalEmails" yaml:"transactionalEmails"`
+	// Limit for events of a recipient, shared by all event names without an override.
+	Events RecipientLimit `json:"events" yaml:"events"`
+	// Limits for specific transactional IDs (e.g. allowing more OTP code emails), counted separately.
+	TransactionalOverrides map[string]RecipientLimit `json:"transactionalOverrides,omitempty" yaml:"transactionalOverrides,omitempty"`
+	// Limits for specific event names, counted separately.
+	EventOverrides map[string]RecipientLimit `json:"eventOverrides,omitempty" yaml:"eventOverrides,omitempty"`
+	// What to do with sends exceeding a limit (default: RecipientActionError).
+	Action RecipientAction `json:"action" yaml:"action"`
+	// With RecipientActionDelay, sends that would have to wait longer than this fail with ErrRecipientLimited instead.
+	// Zero means no maximum, so sends wait as long as their context allows.
+	MaxDelay time.Duration `json:"maxDelay,omitempty" yaml:"maxDelay,omitempty"`
+}
+
+func (p *RecipientPolicy) validate() error {
+	for _, limit := range p.limits() {
+		if limit.Limit < 0 || limit.Limit > 0 && limit.Window <= 0 {
+			return errors.New("invalid recipient limit: limit must not be negative, and window must be positive")
+		}
+	}
+	if p.Action < RecipientActionError || p.Action > RecipientActionDelay {
+		return fmt.Errorf("invalid recipient action: %d", p.Action)
+	}
+	return nil
+}
+
+// limits returns all limits of the policy, including overrides.
+func (p *RecipientPolicy) limits() []RecipientLimit {
+	limits := []RecipientLimit{p.TransactionalEmails, p.Events}
+	for _, limit := range p.TransactionalOverrides {
+		limits = append(limits, limit)
+	}
+	for _, limit := range p.EventOverrides {
+		limits = append(limits, limit)
+	}
+	return limits
+}
+
+// WithRecipientPolicy protects single recipients against send storms, enforcing the given policy before
+// SendTransactionalEmail and SendEvent.
+func WithRecipientPolicy(policy RecipientPolicy) ClientOption {
+	return func(c *clientConfig) {
+		c.recipientPolicy = &policy
+	}
+}
+
+// recipientLimiter enforces a recipient policy, keeping a log of recent send times per recipient in memory.
+type recipientLimiter struct {
+	policy RecipientPolicy
+	now    func() time.Time
+
+	mu        sync.Mutex
+	sends     map[string][]time.Time
+	maxWindow time.Duration
+	lastSweep time.Time
+}
+
+func newRecipientLimiter(policy *RecipientPolicy) (*recipientLimiter, error) {
+	if policy == nil {
+		return nil, nil //nolint:nilnil // no recipient policy configured, which is not an error
+	}
+
+	if err := policy.validate(); err != nil {
+		return nil, err
+	}
+	var maxWindow time.Duration
+	for _, limit := range policy.limits() {
+		maxWindow = max(maxWindow, limit.Window)
+	}
+
+	return &recipientLimiter{
+		policy:    *policy,
+		now:       time.Now,
+		sends:     make(map[string][]time.Time),
+		maxWindow: maxWindow,
+	}, nil
+}
+
+// check records a send to a recipient if it is within the limits. It returns false if the send should not proceed,
+// either together with a RecipientLimitedError, or without an error if the send is dropped.
+// Payloads other than sends are ignored.
+func (r *recipientLimiter) check(ctx context.Context, payload any) (bool, error) {
+	var recipient, key, bucket string
+	var limit RecipientLimit
+	switch p := payload.(type) {
+	case *TransactionalEmail:
+		recipient, key = "email:"+strings.ToLower(p.Email), p.TransactionalID
+		limit, bucket = r.limit(r.policy.TransactionalEmails, r.policy.TransactionalOverrides, "transactional", key)
+	case *Event:
+		if p.Email != nil {
+			recipient = "email:" + strings.ToLower(*p.Email)
+		} else if p.UserID != nil {
+			recipient = "userId:" + *p.UserID
+		}
+		key = p.EventName
+		limit, bucket = r.limit(r.policy.Events, r.policy.EventOverrides, "event", key)
+	default:
+		return true, nil
+	}
+	if limit.Limit == 0 {
+		return true, nil
+	}
+
+	for {
+		retryAfter := r.record(bucket+"|"+recipient, limit)
+		if retryAfter == 0 {
+			return true, nil
+		}
+
+		switch r.policy.Action {
+		case RecipientActionDrop:
+			return false, nil
+		case RecipientActionDelay:
+			if r.policy.MaxDelay == 0 || retryAfter <= r.policy.MaxDelay {
+				timer := time.NewTimer(retryAfter)
+				select {
+				case <-timer.C:
+					continue
+				case <-ctx.Done():
+					timer.Stop()
+					return false, ctx.Err()
+				}
+			}
+		case RecipientActionError:
+		}
+		return false, &RecipientLimitedError{Recipient: recipient, Key: key, RetryAfter: retryAfter}
+	}
+}
+
+// limit returns the limit applying to sends with the given key, and the name of the bucket they are counted in.
+func (r *recipientLimiter) limit(limit RecipientLimit, overrides map[string]RecipientLimit, kind, key string) (RecipientLimit, string) {
+	if override, ok := overrides[key]; ok {
+		return override, kind + "|" + key
+	}
+	return limit, kind
+}
+
+// record records a send for the given bucket key if it is within the limit, and returns 0. Otherwise, it returns
+// the time until the next send would be within the limit.
+func (r *recipientLimiter) record(bucketKey string, limit RecipientLimit) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	r.sweep(now)
+
+	sends := dropBefore(r.sends[bucketKey], now.Add(-limit.Window))
+	if len(sends) >= limit.Limit {
+		r.sends[bucketKey] = sends
+		return sends[len(sends)-limit.Limit].Add(limit.Window).Sub(now)
+	}
+	r.sends[bucketKey] = append(sends, now)
+	return 0
+}
+
+// sweep discards the send logs of recipients without recent sends, so memory doesn't grow unbounded.
+func (r *recipientLimiter) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < r.maxWindow {
+		return
+	}
+	r.lastSweep = now
+	for key, sends := range r.sends {
+		if len(sends) == 0 || sends[len(sends)-1].Before(now.Add(-r.maxWindow)) {
+			delete(r.sends, key)
+		}
+	}
+}
+
+// dropBefore removes all times before the given cutoff from the sorted slice of times.
+func dropBefore(times []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(times) && !times[i].After(cutoff) {
+		i++
+	}
+	return times[i:]
+}
@@ -0,0 +1,92 @@
+package loops
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecipientPolicyError(t *testing.T) {
+	httpClient := &recordingHTTPClient{}
+	client, err := NewClient(WithHTTPClient(httpClient), WithRecipientPolicy(RecipientPolicy{
+		TransactionalEmails:    RecipientLimit{Limit: 2, Window: time.Hour},
+		TransactionalOverrides: map[string]RecipientLimit{"otp-code": {Limit: 5, Window: time.Hour}},
+	}))
+	require.NoError(t, err)
+	now := time.Now()
+	client.recipients.now = func() time.Time { return now }
+
+	send := func(transactionalID, email string) error {
+		return client.SendTransactionalEmail(context.Background(), &TransactionalEmail{
+			TransactionalID: transactionalID,
+			Email:           email,
+		})
+	}
+
+	require.NoError(t, send("welcome", "test@example.com"))
+	require.NoError(t, send("password-reset", "TEST@example.com"))
+	err = send("welcome", "test@example.com")
+	require.ErrorIs(t, err, ErrRecipientLimited)
+	var limitedErr *RecipientLimitedError
+	require.ErrorAs(t, err, &limitedErr)
+	assert.Equal(t, "email:test@example.com", limitedErr.Recipient)
+	assert.Equal(t, time.Hour, limitedErr.RetryAfter)
+
+	// other recipients and overridden templates are counted separately
+	require.NoError(t, send("welcome", "other@example.com"))
+	for range 5 {
+		require.NoError(t, send("otp-code", "test@example.com"))
+	}
+	require.ErrorIs(t, send("otp-code", "test@example.com"), ErrRecipientLimited)
+
+	// the window slides
+	now = now.Add(time.Hour)
+	require.NoError(t, send("welcome", "test@example.com"))
+	assert.Len(t, httpClient.bodies, 9)
+}
+
+func TestRecipientPolicyDrop(t *testing.T) {
+	httpClient := &recordingHTTPClient{}
+	client, err := NewClient(WithHTTPClient(httpClient), WithRecipientPolicy(RecipientPolicy{
+		Events: RecipientLimit{Limit: 1, Window: time.Hour},
+		Action: RecipientActionDrop,
+	}))
+	require.NoError(t, err)
+
+	event := &Event{UserID: String("user_123"), EventName: "signup"}
+	require.NoError(t, client.SendEvent(context.Background(), event))
+	require.NoError(t, client.SendEvent(context.Background(), event))
+	assert.Len(t, httpClient.bodies, 1)
+}
+
+func TestRecipientPolicyDelay(t *testing.T) {
+	httpClient := &recordingHTTPClient{}
+	client, err := NewClient(WithHTTPClient(httpClient), WithRecipientPolicy(RecipientPolicy{
+		Events:   RecipientLimit{Limit: 1, Window: 20 * time.Millisecond},
+		Action:   RecipientActionDelay,
+		MaxDelay: time.Second,
+	}))
+	require.NoError(t, err)
+
+	event := &Event{Email: String("test@example.com"), EventName: "signup"}
+	start := time.Now()
+	require.NoError(t, client.SendEvent(context.Background(), event))
+	require.NoError(t, client.SendEvent(context.Background(), event))
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	assert.Len(t, httpClient.bodies, 2)
+
+	// delays longer than the context allows fail
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, client.SendEvent(ctx, event), context.DeadlineExceeded)
+}
+
+func TestRecipientPolicyInvalid(t *testing.T) {
+	_, err := NewClient(WithRecipientPolicy(RecipientPolicy{Events: RecipientLimit{Limit: 1}}))
+	require.Error(t, err)
+	_, err = NewClient(WithRecipientPolicy(RecipientPolicy{Action: 42}))
+	require.Error(t, err)
+}
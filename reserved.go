@@ -0,0 +1,109 @@
+package loops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// reservedPropertyNames are the names CreateContactProperty rejects for new contact properties: the standard contact
+// fields, and createdAt, a default property of every contact even though it is no field of Contact.
+var reservedPropertyNames = append(contactFields[:len(contactFields):len(contactFields)], "createdAt")
+
+// ErrReservedProperty is returned (wrapped in a ReservedPropertyError) for custom contact properties whose name
+// collides with a standard contact property.
+var ErrReservedProperty = errors.New("custom property name is reserved")
+
+// ErrInvalidPropertyName is returned by CreateContactProperty for property names that are not in camelCase.
+var ErrInvalidPropertyName = errors.New("invalid contact property name")
+
+// ReservedPropertyError is returned for custom contact properties whose name collides with a standard contact
+// property, such as Properties["email"]. Use the standard field instead.
+type ReservedPropertyError struct {
+	Key string
+}
+
+func (e *ReservedPropertyError) Error() string {
+	return fmt.Sprintf("custom property %q collides with the standard contact property of the same name", e.Key)
+}
+
+func (e *ReservedPropertyError) Is(target error) bool {
+	return target == ErrReservedProperty
+}
+
+// checkReservedProperties returns a ReservedPropertyError for the first custom property colliding with a standard
+// contact field.
+func checkReservedProperties(properties map[string]any) error {
+	if len(properties) == 0 {
+		return nil
+	}
+	for _, field := range contactFields {
+		if _, ok := properties[field]; ok {
+			return &ReservedPropertyError{Key: field}
+		}
+	}
+	return nil
+}
+
+// withoutReservedProperties returns a copy of the properties without the ones colliding with standard contact fields,
+// and the keys of the removed ones. If there are none, the properties are returned as is.
+func withoutReservedProperties(properties map[string]any) (map[string]any, []string) {
+	if checkReservedProperties(properties) == nil {
+		return properties, nil
+	}
+	var removed []string
+	filtered := make(map[string]any, len(properties))
+	for key, value := range properties {
+		if slices.Contains(contactFields[:], key) {
+			removed = append(removed, key)
+			continue
+		}
+		filtered[key] = value
+	}
+	slices.Sort(removed)
+	return filtered, removed
+}
+
+// ReservedPropertyHook is called for every custom contact property dropped because its name collides with a
+// standard contact field.
+type ReservedPropertyHook func(ctx context.Context, err *ReservedPropertyError)
+
+// WithReservedPropertyWarnings makes the client drop custom contact properties colliding with a standard contact
+// field, instead of failing the call with ErrReservedProperty. The standard field is sent instead, and the hook is
+// called for every dropped property, e.g. to log a warning.
+func WithReservedPropertyWarnings(hook ReservedPropertyHook) ClientOption {
+	return func(c *clientConfig) {
+		c.reservedPropertyHook = hook
+	}
+}
+
+// dropReservedProperties returns the payload with custom properties colliding with standard contact fields dropped,
+// if the client is configured to warn about them rather than failing. Otherwise, the payload is returned as is.
+func (c *Client) dropReservedProperties(ctx context.Context, payload any) any {
+	if c.reservedPropertyHook == nil {
+		return payload
+	}
+
+	var removed []string
+	switch p := payload.(type) {
+	case *Contact:
+		properties, dropped := withoutReservedProperties(p.Properties)
+		if len(dropped) > 0 {
+			contact := *p
+			contact.Properties = properties
+			payload, removed = &contact, dropped
+		}
+	case *ContactUpdate:
+		properties, dropped := withoutReservedProperties(p.Properties)
+		if len(dropped) > 0 {
+			update := *p
+			update.Properties = properties
+			payload, removed = &update, dropped
+		}
+	}
+	for _, key := range removed {
+		c.reservedPropertyHook(ctx, &ReservedPropertyError{Key: key})
+	}
+	return payload
+}
@@ -0,0 +1,50 @@
+package loops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReservedPropertyRejected(t *testing.T) {
+	httpClient := &recordingHTTPClient{}
+	client, err := NewClient(WithHTTPClient(httpClient))
+	require.NoError(t, err)
+
+	_, err = client.CreateContact(context.Background(), &Contact{
+		Email:      "test@example.com",
+		Properties: map[string]any{"subscribed": true},
+	})
+	require.ErrorIs(t, err, ErrReservedProperty)
+	assert.Empty(t, httpClient.bodies)
+}
+
+func TestReservedPropertyWarnings(t *testing.T) {
+	httpClient := &recordingHTTPClient{}
+	var warnings []string
+	client, err := NewClient(WithHTTPClient(httpClient), WithReservedPropertyWarnings(func(_ context.Context, err *ReservedPropertyError) {
+		warnings = append(warnings, err.Key)
+	}))
+	require.NoError(t, err)
+
+	contact := &Contact{
+		Email:      "test@example.com",
+		Properties: map[string]any{"subscribed": true, "email": "other@example.com", "favoriteColor": "blue"},
+	}
+	_, err = client.CreateContact(context.Background(), contact)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"email", "subscribed"}, warnings)
+	require.Len(t, httpClient.bodies, 1)
+	assert.JSONEq(t, `{"id":"","email":"test@example.com","subscribed":false,"favoriteColor":"blue"}`, httpClient.bodies[0])
+	assert.Len(t, contact.Properties, 3, "the caller's contact must not be modified")
+
+	_, err = client.UpdateContactFields(context.Background(), &ContactUpdate{
+		Email:      "test@example.com",
+		Properties: map[string]any{"userGroup": "Astronauts"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"email", "subscribed", "userGroup"}, warnings)
+	assert.JSONEq(t, `{"email":"test@example.com"}`, httpClient.bodies[1])
+}
@@ -0,0 +1,131 @@
+package loops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrTeamMismatch is returned (wrapped in a TeamMismatchError) for all calls of a client whose API key belongs to
+// a different team than expected.
+var ErrTeamMismatch = errors.New("api key belongs to an unexpected team")
+
+// TeamMismatchError is returned for all calls of a client whose API key belongs to a different team than expected.
+type TeamMismatchError struct {
+	// The team the API key was expected to belong to.
+	Expected string
+	// The team the API key actually belongs to.
+	Actual string
+}
+
+func (e *TeamMismatchError) Error() string {
+	return fmt.Sprintf("api key belongs to team %q, but expected team %q", e.Actual, e.Expected)
+}
+
+func (e *TeamMismatchError) Is(target error) bool {
+	return target == ErrTeamMismatch
+}
+
+// WithExpectedTeam verifies that the API key belongs to the given team (as returned by TestAPIKey), before the client
+// sends anything. The team is verified on first use, or explicitly by calling Client.VerifyTeam. On a mismatch,
+// all calls fail with ErrTeamMismatch.
+func WithExpectedTeam(team string) ClientOption {
+	return func(c *clientConfig) {
+		c.expectedTeam = team
+	}
+}
+
+// WithExpectedTeamForEnvironment is like WithExpectedTeam, but looks up the expected team of the given environment
+// in a map of environment name to team name, e.g. {"staging": "Tilebox Staging", "production": "Tilebox"}.
+// Creating the client fails if the environment is not in the map.
+func WithExpectedTeamForEnvironment(environment string, teams map[string]string) ClientOption {
+	return func(c *clientConfig) {
+		team, ok := teams[environment]
+		if !ok {
+			c.errs = append(c.errs, fmt.Errorf("no expected team configured for environment %q", environment))
+			return
+		}
+		c.expectedTeam = team
+	}
+}
+
+// VerifyTeam verifies that the API key belongs to the team configured with WithExpectedTeam, e.g. to fail fast at
+// startup. It returns a TeamMismatchError if it doesn't, and nil if no team is expected.
+func (c *Client) VerifyTeam(ctx context.Context) error {
+	if c.team == nil {
+		return nil
+	}
+	return c.team.verify(ctx, c)
+}
+
+// teamVerifier verifies the team of the API key once, and remembers the result.
+type teamVerifier struct {
+	expected string
+	lock     semaphore // a context aware mutex, so callers waiting for the first verification can give up
+
+	mu         sync.Mutex
+	verified   bool
+	mismatch   error
+	generation int // incremented on reset, so results for a previous API key are discarded
+}
+
+func newTeamVerifier(expected string) *teamVerifier {
+	if expected == "" {
+		return nil
+	}
+	return &teamVerifier{expected: expected, lock: newSemaphore(1)}
+}
+
+// result returns whether the team has already been verified, and the mismatch error if it didn't match.
+func (t *teamVerifier) result() (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.verified, t.mismatch
+}
+
+// reset forgets the result of a previous verification, e.g. after the API key changed.
+func (t *teamVerifier) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.verified = false
+	t.mismatch = nil
+	t.generation++
+}
+
+// verify checks the team of the API key, unless that has already been done. Errors other than a mismatch, such as
+// an unreachable API, are not remembered, so verification is retried on the next call.
+func (t *teamVerifier) verify(ctx context.Context, c *Client) error {
+	if verified, err := t.result(); verified {
+		return err
+	}
+
+	if err := t.lock.acquire(ctx); err != nil {
+		return fmt.Errorf("failed to verify api key team: %w", err)
+	}
+	defer t.lock.release()
+	if verified, err := t.result(); verified { // verified by another call in the meantime
+		return err
+	}
+	t.mu.Lock()
+	generation := t.generation
+	t.mu.Unlock()
+
+	info, err := c.TestAPIKey(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to verify api key team: %w", err)
+	}
+
+	var mismatch error
+	if info.TeamName != t.expected {
+		mismatch = &TeamMismatchError{Expected: t.expected, Actual: info.TeamName}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.generation == generation {
+		t.verified = true
+		t.mismatch = mismatch
+	}
+	return mismatch
+}
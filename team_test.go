@@ -0,0 +1,105 @@
+package loops
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// teamHTTPClient answers API key checks with the given team name, and all other requests successfully.
+type teamHTTPClient struct {
+	teamName     string
+	unreachable  bool
+	apiKeyChecks int
+	requests     int
+}
+
+func (t *teamHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	if strings.HasSuffix(req.URL.Path, "/api-key") {
+		t.apiKeyChecks++
+		if t.unreachable {
+			return nil, errors.New("connection refused")
+		}
+		return jsonResponse(http.StatusOK, `{"success":true,"teamName":"`+t.teamName+`"}`), nil
+	}
+	t.requests++
+	return jsonResponse(http.StatusOK, `{"success":true,"message":""}`), nil
+}
+
+func TestExpectedTeamVerifiedOnFirstUse(t *testing.T) {
+	httpClient := &teamHTTPClient{teamName: "Tilebox Staging"}
+	client, err := NewClient(WithHTTPClient(httpClient), WithExpectedTeam("Tilebox Staging"))
+	require.NoError(t, err)
+
+	event := &Event{Email: String("test@example.com"), EventName: "signup"}
+	require.NoError(t, client.SendEvent(context.Background(), event))
+	require.NoError(t, client.SendEvent(context.Background(), event))
+	assert.Equal(t, 1, httpClient.apiKeyChecks)
+	assert.Equal(t, 2, httpClient.requests)
+}
+
+func TestExpectedTeamMismatch(t *testing.T) {
+	httpClient := &teamHTTPClient{teamName: "Tilebox"}
+	client, err := NewClient(WithHTTPClient(httpClient), WithExpectedTeam("Tilebox Staging"))
+	require.NoError(t, err)
+
+	err = client.VerifyTeam(context.Background())
+	require.ErrorIs(t, err, ErrTeamMismatch)
+	var mismatchErr *TeamMismatchError
+	require.ErrorAs(t, err, &mismatchErr)
+	assert.Equal(t, "Tilebox", mismatchErr.Actual)
+	assert.Equal(t, "Tilebox Staging", mismatchErr.Expected)
+
+	err = client.SendEvent(context.Background(), &Event{Email: String("test@example.com"), EventName: "signup"})
+	require.ErrorIs(t, err, ErrTeamMismatch)
+	assert.Equal(t, 1, httpClient.apiKeyChecks)
+	assert.Zero(t, httpClient.requests)
+}
+
+func TestExpectedTeamRetriedAfterFailure(t *testing.T) {
+	httpClient := &teamHTTPClient{teamName: "Tilebox Staging", unreachable: true}
+	client, err := NewClient(WithHTTPClient(httpClient), WithExpectedTeam("Tilebox Staging"))
+	require.NoError(t, err)
+
+	err = client.VerifyTeam(context.Background())
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrTeamMismatch)
+
+	httpClient.unreachable = false
+	require.NoError(t, client.VerifyTeam(context.Background()))
+	assert.Equal(t, 2, httpClient.apiKeyChecks)
+}
+
+func TestExpectedTeamForEnvironment(t *testing.T) {
+	teams := map[string]string{"staging": "Tilebox Staging", "production": "Tilebox"}
+
+	httpClient := &teamHTTPClient{teamName: "Tilebox Staging"}
+	client, err := NewClient(WithHTTPClient(httpClient), WithExpectedTeamForEnvironment("staging", teams))
+	require.NoError(t, err)
+	require.NoError(t, client.VerifyTeam(context.Background()))
+
+	_, err = NewClient(WithExpectedTeamForEnvironment("development", teams))
+	require.Error(t, err)
+}
+
+func TestExpectedTeamFixture(t *testing.T) {
+	client := newReplayTestClient(t, "test-api-key.replay.json", WithExpectedTeam("Tilebox Staging"))
+	require.NoError(t, client.VerifyTeam(context.Background()))
+}
+
+func TestSetAPIKeyVerifiesTeamAgain(t *testing.T) {
+	httpClient := &teamHTTPClient{teamName: "Tilebox Staging"}
+	client, err := NewClient(WithHTTPClient(httpClient), WithExpectedTeam("Tilebox Staging"))
+	require.NoError(t, err)
+	require.NoError(t, client.VerifyTeam(context.Background()))
+
+	httpClient.teamName = "Tilebox"
+	client.SetAPIKey("production-key")
+	require.ErrorIs(t, client.VerifyTeam(context.Background()), ErrTeamMismatch)
+	assert.Equal(t, 2, httpClient.apiKeyChecks)
+}